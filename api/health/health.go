@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "OK"
+	StatusDown = "DOWN"
+)
+
+// how long a single dependency may take before it counts as down
+const checkTimeout = time.Second
+
+// a dependency checked by the readiness route
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+	// optional checks are reported but do not fail the readiness
+	Optional bool
+}
+
+// result of a single dependency
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// handlers for the health routes
+type Handler struct {
+	checks []Check
+}
+
+// create the handlers checking the dependencies on readiness
+func NewHandler(checks ...Check) *Handler {
+	return &Handler{checks: checks}
+}
+
+// handler for liveness, the process is able to serve the request
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: StatusOK})
+}
+
+// handler for readiness, every dependency has to be reachable
+func (h *Handler) Readyz(c *gin.Context) {
+	var res HealthResponse
+	res.Status = StatusOK
+	res.Checks = make(map[string]CheckResult, len(h.checks))
+
+	// run the checks at the same time so the slowest one decides the latency
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := runCheck(check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			res.Checks[check.Name] = result
+			if result.Status != StatusOK && !check.Optional {
+				res.Status = StatusDown
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	if res.Status != StatusOK {
+		c.JSON(http.StatusServiceUnavailable, res)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// run the probe with the timeout, a hanging probe counts as down
+func runCheck(check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Probe(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		logrus.Warnf("readiness check %s failed: %v", check.Name, err)
+		return CheckResult{Status: StatusDown, Error: err.Error()}
+	}
+
+	return CheckResult{Status: StatusOK}
+}
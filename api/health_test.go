@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"order-service/api/health"
+	"order-service/models"
+	"order-service/pkgs/e"
+	"order-service/services/distance"
+	"testing"
+)
+
+// probe for a dependency which is reachable
+func okProbe(ctx context.Context) error {
+	return nil
+}
+
+// helper function to call a health route
+func callHealth(t *testing.T, path string, checks ...health.Check) (int, health.HealthResponse) {
+	svc := models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil)
+	r := InitRouter(svc, checks...)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	r.ServeHTTP(w, req)
+
+	var res health.HealthResponse
+	if err := parseJson(w.Body, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	return w.Code, res
+}
+
+// test success from liveness even when a dependency is down
+func TestHealthz(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	down := health.Check{Name: "mysql", Probe: func(ctx context.Context) error { return errors.New("connection refused") }}
+	code, res := callHealth(t, "/healthz", down)
+
+	a.Equal(http.StatusOK, code, "server should return back 200 OK")
+	a.Equal(health.StatusOK, res.Status, "process should be OK")
+}
+
+// test success from readiness with every dependency reachable
+func TestReadyz(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	calc := distance.NewMockCalculator(100, nil)
+	code, res := callHealth(t, "/readyz",
+		health.Check{Name: "mysql", Probe: okProbe},
+		health.Check{Name: "distance", Probe: distance.Probe(calc)},
+	)
+
+	a.Equal(http.StatusOK, code, "server should return back 200 OK")
+	a.Equal(health.StatusOK, res.Status, "service should be OK")
+	a.Equal(health.StatusOK, res.Checks["mysql"].Status, "mysql should be OK")
+	a.Equal(health.StatusOK, res.Checks["distance"].Status, "distance should be OK")
+}
+
+// test for readiness when the distance provider does not know the probe route
+func TestReadyz_Distance_Unknown(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	calc := distance.NewMockCalculator(0, e.ErrDistanceUnknown)
+	code, res := callHealth(t, "/readyz", health.Check{Name: "distance", Probe: distance.Probe(calc)})
+
+	a.Equal(http.StatusOK, code, "server should return back 200 OK")
+	a.Equal(health.StatusOK, res.Checks["distance"].Status, "provider answered so it should be OK")
+}
+
+// test for error response from readiness with a dependency down
+func TestReadyz_Dependency_Down(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	calc := distance.NewMockCalculator(0, errors.New("over query limit"))
+	code, res := callHealth(t, "/readyz",
+		health.Check{Name: "mysql", Probe: func(ctx context.Context) error { return errors.New("connection refused") }},
+		health.Check{Name: "distance", Probe: distance.Probe(calc)},
+	)
+
+	a.Equal(http.StatusServiceUnavailable, code, "server should return back 503 Service Unavailable")
+	a.Equal(health.StatusDown, res.Status, "service should be DOWN")
+	a.Equal(health.CheckResult{Status: health.StatusDown, Error: "connection refused"}, res.Checks["mysql"], "mysql should be DOWN")
+	a.Equal(health.CheckResult{Status: health.StatusDown, Error: "over query limit"}, res.Checks["distance"], "distance should be DOWN")
+}
+
+// test for error response from readiness with a dependency hanging
+func TestReadyz_Dependency_Timeout(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	code, res := callHealth(t, "/readyz",
+		health.Check{Name: "mysql", Probe: okProbe},
+		health.Check{Name: "distance", Probe: hang},
+	)
+
+	a.Equal(http.StatusServiceUnavailable, code, "server should return back 503 Service Unavailable")
+	a.Equal(health.StatusOK, res.Checks["mysql"].Status, "mysql should be OK")
+	a.Equal(health.StatusDown, res.Checks["distance"].Status, "distance should be DOWN")
+}
+
+// test for readiness with an optional check down
+func TestReadyz_Optional_Down(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	code, res := callHealth(t, "/readyz",
+		health.Check{Name: "mysql", Probe: okProbe},
+		health.Check{Name: "distance_circuit", Probe: func(ctx context.Context) error { return errors.New("circuit is open") }, Optional: true},
+	)
+
+	a.Equal(http.StatusOK, code, "server should return back 200 OK")
+	a.Equal(health.StatusOK, res.Status, "service should be OK")
+	a.Equal(health.CheckResult{Status: health.StatusDown, Error: "circuit is open"}, res.Checks["distance_circuit"], "circuit should be reported DOWN")
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"order-service/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// route label for the requests which did not match any route
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "order_service",
+		Name:      "http_requests_total",
+		Help:      "Handled HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "order_service",
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration)
+}
+
+// gin middleware recording every request, it has to be used before the routes are added
+// Note: gin has no route pattern on the context, so the route is found by the handler name
+func Middleware(r *gin.Engine) gin.HandlerFunc {
+	var (
+		once   sync.Once
+		routes map[string]string
+	)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// every route is added by the time the first request comes in
+		once.Do(func() {
+			routes = make(map[string]string)
+			for _, route := range r.Routes() {
+				routes[route.Method+" "+route.Handler] = route.Path
+			}
+		})
+
+		route, ok := routes[c.Request.Method+" "+c.HandlerName()]
+		if !ok {
+			route = unmatchedRoute
+		}
+
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// handler exposing the metrics for prometheus
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// collector for the number of orders by status, counted on every scrape
+type orderStatusCollector struct {
+	svc  *models.OrderService
+	desc *prometheus.Desc
+}
+
+// create the collector for the orders of the service
+func NewOrderStatusCollector(svc *models.OrderService) prometheus.Collector {
+	return &orderStatusCollector{
+		svc: svc,
+		desc: prometheus.NewDesc(
+			"order_service_orders",
+			"Number of orders by status.",
+			[]string{"status"}, nil,
+		),
+	}
+}
+
+func (c *orderStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *orderStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	// the registry gives no context to the collectors
+	counts, err := c.svc.CountOrdersByStatus(context.Background())
+	if err != nil {
+		logrus.Error(err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	// every known status is reported even without orders so its series reads 0 instead of going away
+	for _, status := range models.Statuses {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(counts[status]), status)
+		delete(counts, status)
+	}
+
+	// statuses left over from older versions are still counted
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), status)
+	}
+}
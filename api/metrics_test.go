@@ -0,0 +1,71 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"order-service/api/metrics"
+	"order-service/models"
+	"order-service/services/distance"
+	"strings"
+	"testing"
+)
+
+// test for the requests are recorded by route pattern and status
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router
+	svc := models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil)
+	r := InitRouter(svc)
+
+	// make requests which are recorded
+	for _, path := range []string{"/orders/98765", "/orders/abc", "/not-a-route"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+	}
+
+	// scrape the metrics
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	r.ServeHTTP(w, req)
+
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	body := w.Body.String()
+	a.Contains(body, `order_service_http_requests_total{method="GET",route="/orders/:id",status="404"}`, "not found should be recorded by route")
+	a.Contains(body, `order_service_http_requests_total{method="GET",route="/orders/:id",status="400"}`, "bad request should be recorded by route")
+	a.Contains(body, `order_service_http_requests_total{method="GET",route="unmatched",status="404"}`, "unknown path should be unmatched")
+	a.Contains(body, `order_service_http_request_duration_seconds_bucket{method="GET",route="/orders/:id",status="404"`, "duration should be recorded by route")
+}
+
+// test for the orders are counted by status
+func TestMetrics_Order_Status(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	repo := models.NewMemoryOrderRepository()
+	createOrder(t, repo, models.StatusUnassigned)
+	createOrder(t, repo, models.StatusUnassigned)
+	createOrder(t, repo, models.StatusTaken)
+
+	svc := models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil)
+	expected := `
+# HELP order_service_orders Number of orders by status.
+# TYPE order_service_orders gauge
+order_service_orders{status="CANCELLED"} 0
+order_service_orders{status="DELIVERED"} 0
+order_service_orders{status="FAILED"} 0
+order_service_orders{status="PICKED_UP"} 0
+order_service_orders{status="TAKEN"} 1
+order_service_orders{status="UNASSIGNED"} 2
+`
+
+	err := testutil.CollectAndCompare(metrics.NewOrderStatusCollector(svc), strings.NewReader(expected))
+	a.Nil(err, "orders should be counted by status")
+}
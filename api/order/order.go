@@ -1,41 +1,145 @@
 package order
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
-	"github.com/jinzhu/gorm"
 	"github.com/sirupsen/logrus"
 	"net/http"
 	r "order-service/api/requests"
 	"order-service/models"
 	"order-service/pkgs/e"
+	"order-service/services/distance"
 	"strconv"
+	"strings"
 )
 
-type TakeOrderResponse struct {
+// non-standard status for a client which closed the request before the response
+const StatusClientClosedRequest = 499
+
+// header of the courier the request is made for, set by the gateway which authenticated the courier
+const CourierHeader = "X-Courier-ID"
+
+// result of a successful update, it is not a status of the order
+const ResultSuccess = "SUCCESS"
+
+type UpdateOrderResponse struct {
 	Status string `json:"status"`
 }
 
+// page of the orders listed by the cursor, the next cursor is null on the last page
+type OrdersPage struct {
+	Orders     []*models.Order `json:"orders"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+// handlers for the order routes backed by an order service
+type Handler struct {
+	svc *models.OrderService
+}
+
+// create the handlers on top of the order service
+func NewHandler(svc *models.OrderService) *Handler {
+	return &Handler{svc: svc}
+}
+
+// function to respond to the errors of a timed out or canceled request, true when it is handled
+func handleContextErr(c *gin.Context, err error) bool {
+	// the client has gone away so nobody reads the response
+	if c.Request.Context().Err() == context.Canceled {
+		c.AbortWithStatus(StatusClientClosedRequest)
+		return true
+	}
+
+	if err == e.ErrTimeout || err == context.DeadlineExceeded {
+		c.JSON(http.StatusGatewayTimeout, e.CreateErr(e.ErrTimeout))
+		return true
+	}
+
+	return false
+}
+
+// function to pick the courier of the request, the header wins over the body and they can not disagree,
+// both are trimmed so the courier is stored and compared the same as it is listed
+func courierID(c *gin.Context, body string) (string, bool) {
+	body = strings.TrimSpace(body)
+	header := strings.TrimSpace(c.GetHeader(CourierHeader))
+	if header == "" {
+		return body, true
+	}
+
+	if body != "" && body != header {
+		return "", false
+	}
+
+	return header, true
+}
+
+// function to name the address field of the endpoint of the order on the request
+func addressField(endpoint string) string {
+	if strings.HasPrefix(endpoint, "stops[") {
+		return endpoint + ".address"
+	}
+
+	return endpoint + "_address"
+}
+
 // handler for creating order
-func CreateOrder(c *gin.Context) {
+func (h *Handler) CreateOrder(c *gin.Context) {
 	var req r.CreateOrderRequest
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrOrderRequestInvalid))
 		return
 	}
 
-	// make sure the body is present
-	if req.Origin == nil || req.Destination == nil {
-		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrOrderRequestInvalid))
+	// make sure the coordinates or the addresses are present and valid
+	if fields := req.Validate(); len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, e.CreateValidationErr(e.ErrOrderRequestValidation, fields))
 		return
 	}
 
-	o, err := models.CreateOrder(req.Origin, req.Destination)
+	src := models.Endpoint{Coordinate: req.Origin, Address: req.OriginAddress}
+	des := models.Endpoint{Coordinate: req.Destination, Address: req.DestinationAddress}
+	stops := make([]models.Endpoint, 0, len(req.Stops))
+	for _, stop := range req.Stops {
+		stops = append(stops, models.Endpoint{Coordinate: stop.Location, Address: stop.Address})
+	}
+
+	o, err := h.svc.CreateOrder(c.Request.Context(), src, des, req.Options(), stops...)
 	if err != nil {
+		// the geocoder does not know the address
+		if aerr, ok := err.(*models.AddressNotFoundError); ok {
+			fields := []e.FieldError{{Field: addressField(aerr.Endpoint), Reason: "could not be found"}}
+			c.JSON(http.StatusBadRequest, e.CreateValidationErr(e.ErrAddressNotFound, fields))
+			return
+		}
+		// the service runs without a geocoder
+		if err == e.ErrGeocodingDisabled {
+			c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrGeocodingDisabled))
+			return
+		}
+
+		// the provider can not route with the options of the request
+		if uerr, ok := err.(*distance.UnsupportedOptionsError); ok {
+			fields := []e.FieldError{{Field: uerr.Field, Reason: uerr.Reason}}
+			c.JSON(http.StatusBadRequest, e.CreateValidationErr(e.ErrRouteOptionsUnsupported, fields))
+			return
+		}
+
 		// special case when google map does not know the distance
 		if err == e.ErrDistanceUnknown {
 			c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrDistanceUnknown))
 			return
 		}
+		// the distance provider is down, the client can try again later
+		if err == e.ErrDistanceUnavailable {
+			c.JSON(http.StatusServiceUnavailable, e.CreateErr(e.ErrDistanceUnavailable))
+			return
+		}
+
+		// the request ran out of time or the client is gone
+		if handleContextErr(c, err) {
+			return
+		}
 
 		// other exceptions
 		logrus.Error(err)
@@ -47,7 +151,7 @@ func CreateOrder(c *gin.Context) {
 }
 
 // handler for get list of the order
-func GetOrders(c *gin.Context) {
+func (h *Handler) GetOrders(c *gin.Context) {
 	var req r.GetOrderRequest
 	if err := c.BindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrQueryStringInvalid))
@@ -60,8 +164,31 @@ func GetOrders(c *gin.Context) {
 		return
 	}
 
-	os, err := models.GetOrders(req.Page, req.Limit)
-	if err != nil && err != gorm.ErrRecordNotFound {
+	// the cursor, even an empty one for the first page, pages by the cursor instead of the page
+	_, byCursor := c.GetQuery("cursor")
+
+	// make sure the filters and the sort are known
+	fields := req.Validate()
+	if byCursor {
+		fields = append(fields, req.ValidateCursor()...)
+	}
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, e.CreateValidationErr(e.ErrQueryStringInvalid, fields))
+		return
+	}
+
+	if byCursor {
+		h.getOrdersAfter(c, req)
+		return
+	}
+
+	os, err := h.svc.GetOrders(c.Request.Context(), req.Filter(), req.Page, req.Limit)
+	if err != nil {
+		// the request ran out of time or the client is gone
+		if handleContextErr(c, err) {
+			return
+		}
+
 		logrus.Error(err)
 		c.JSON(http.StatusInternalServerError, e.CreateErr(e.ErrInternalError))
 		return
@@ -70,8 +197,31 @@ func GetOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, os)
 }
 
-// handler for update an existing order
-func TakeOrder(c *gin.Context) {
+// function to respond with the orders after the cursor of the request and the cursor of the next ones
+func (h *Handler) getOrdersAfter(c *gin.Context, req r.GetOrderRequest) {
+	os, next, err := h.svc.GetOrdersAfter(c.Request.Context(), req.CursorFilter(), req.Limit)
+	if err != nil {
+		// the request ran out of time or the client is gone
+		if handleContextErr(c, err) {
+			return
+		}
+
+		logrus.Error(err)
+		c.JSON(http.StatusInternalServerError, e.CreateErr(e.ErrInternalError))
+		return
+	}
+
+	res := OrdersPage{Orders: os}
+	if next != nil {
+		cursor := next.Encode()
+		res.NextCursor = &cursor
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// handler for get a single order
+func (h *Handler) GetOrder(c *gin.Context) {
 	// try to parse the id to int64
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -79,30 +229,219 @@ func TakeOrder(c *gin.Context) {
 		return
 	}
 
-	var req r.TakeOrderRequest
-	if err := c.BindJSON(&req); err != nil {
+	o, err := h.svc.GetOrder(c.Request.Context(), id)
+	if err != nil {
+		// order is not found
+		if err == e.ErrOrderNotExist {
+			c.JSON(http.StatusNotFound, e.CreateErr(e.ErrOrderNotExist))
+			return
+		}
+
+		// the request ran out of time or the client is gone
+		if handleContextErr(c, err) {
+			return
+		}
+
+		// other exceptions
+		logrus.Error(err)
+		c.JSON(http.StatusInternalServerError, e.CreateErr(e.ErrInternalError))
+		return
+	}
+
+	c.JSON(http.StatusOK, o)
+}
+
+// handler for update the status of an existing order
+func (h *Handler) UpdateOrder(c *gin.Context) {
+	// try to parse the id to int64
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrOrderRequestInvalid))
 		return
 	}
 
-	// got anything other than TAKEN
-	if req.Status != models.StatusTaken {
+	var req r.UpdateOrderRequest
+	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrOrderRequestInvalid))
 		return
 	}
 
-	err = models.TakeOrder(id)
+	// the courier may come from the header instead of the body
+	courier, ok := courierID(c, req.CourierID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrCourierMismatch))
+		return
+	}
+	req.CourierID = courier
+
+	// got anything other than a known status
+	if fields := req.Validate(); len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, e.CreateValidationErr(e.ErrOrderRequestInvalid, fields))
+		return
+	}
+
+	err = h.svc.UpdateStatus(c.Request.Context(), id, req.Status, req.CourierID)
 	if err != nil {
 		// order is not found
-		if err == gorm.ErrRecordNotFound {
+		if err == e.ErrOrderNotExist {
 			c.JSON(http.StatusNotFound, e.CreateErr(e.ErrOrderNotExist))
 			return
 		}
-		// order has already been taken
+		// order can not move to the status from its current one
+		if terr, ok := err.(*e.TransitionError); ok {
+			c.JSON(http.StatusConflict, e.CreateTransitionErr(terr))
+			return
+		}
+		// order has been taken by someone else in the meantime
 		if err == e.ErrOrderAlreadyTaken {
 			c.JSON(http.StatusConflict, e.CreateErr(e.ErrOrderAlreadyTaken))
 			return
 		}
+		// the courier has just released the order
+		if err == e.ErrOrderReleaseCooldown {
+			c.JSON(http.StatusConflict, e.CreateErr(e.ErrOrderReleaseCooldown))
+			return
+		}
+		// only the courier who took the order moves it on
+		if err == e.ErrOrderNotAssignee {
+			c.JSON(http.StatusForbidden, e.CreateErr(e.ErrOrderNotAssignee))
+			return
+		}
+
+		// the request ran out of time or the client is gone
+		if handleContextErr(c, err) {
+			return
+		}
+
+		// other exceptions
+		logrus.Error(err)
+		c.JSON(http.StatusInternalServerError, e.CreateErr(e.ErrInternalError))
+		return
+	}
+
+	// respond with the order as it is after the update so the client gets the times of the status
+	o, err := h.svc.GetOrder(c.Request.Context(), id)
+	if err != nil {
+		// the request ran out of time or the client is gone
+		if handleContextErr(c, err) {
+			return
+		}
+
+		logrus.Error(err)
+		c.JSON(http.StatusInternalServerError, e.CreateErr(e.ErrInternalError))
+		return
+	}
+
+	c.JSON(http.StatusOK, o)
+}
+
+// handler for cancel an existing order with the reason
+func (h *Handler) CancelOrder(c *gin.Context) {
+	// try to parse the id to int64
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrOrderRequestInvalid))
+		return
+	}
+
+	var req r.CancelOrderRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrOrderRequestInvalid))
+		return
+	}
+
+	// make sure the reason is known and who cancelled is present
+	if fields := req.Validate(); len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, e.CreateValidationErr(e.ErrOrderRequestValidation, fields))
+		return
+	}
+
+	err = h.svc.CancelOrder(c.Request.Context(), id, req.Cancellation())
+	if err != nil {
+		// order is not found
+		if err == e.ErrOrderNotExist {
+			c.JSON(http.StatusNotFound, e.CreateErr(e.ErrOrderNotExist))
+			return
+		}
+		// order is already over
+		if err == e.ErrOrderAlreadyCancelled || err == e.ErrOrderAlreadyDelivered {
+			c.JSON(http.StatusConflict, e.CreateErr(err))
+			return
+		}
+		// order is on its way and can not be cancelled anymore
+		if terr, ok := err.(*e.TransitionError); ok {
+			c.JSON(http.StatusConflict, e.CreateTransitionErr(terr))
+			return
+		}
+
+		// the request ran out of time or the client is gone
+		if handleContextErr(c, err) {
+			return
+		}
+
+		// other exceptions
+		logrus.Error(err)
+		c.JSON(http.StatusInternalServerError, e.CreateErr(e.ErrInternalError))
+		return
+	}
+
+	var res UpdateOrderResponse
+	res.Status = ResultSuccess
+
+	c.JSON(http.StatusOK, res)
+}
+
+// handler for give a taken order back to the pool
+func (h *Handler) ReleaseOrder(c *gin.Context) {
+	// try to parse the id to int64
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrOrderRequestInvalid))
+		return
+	}
+
+	var req r.ReleaseOrderRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrOrderRequestInvalid))
+		return
+	}
+
+	// the courier may come from the header instead of the body
+	courier, ok := courierID(c, req.CourierID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, e.CreateErr(e.ErrCourierMismatch))
+		return
+	}
+	req.CourierID = courier
+
+	// make sure the courier is present
+	if fields := req.Validate(); len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, e.CreateValidationErr(e.ErrOrderRequestValidation, fields))
+		return
+	}
+
+	err = h.svc.ReleaseOrder(c.Request.Context(), id, req.CourierID)
+	if err != nil {
+		// order is not found
+		if err == e.ErrOrderNotExist {
+			c.JSON(http.StatusNotFound, e.CreateErr(e.ErrOrderNotExist))
+			return
+		}
+		// only the courier who took the order can release it
+		if err == e.ErrOrderNotAssignee {
+			c.JSON(http.StatusForbidden, e.CreateErr(e.ErrOrderNotAssignee))
+			return
+		}
+		// order is not taken
+		if terr, ok := err.(*e.TransitionError); ok {
+			c.JSON(http.StatusConflict, e.CreateTransitionErr(terr))
+			return
+		}
+
+		// the request ran out of time or the client is gone
+		if handleContextErr(c, err) {
+			return
+		}
 
 		// other exceptions
 		logrus.Error(err)
@@ -110,8 +449,8 @@ func TakeOrder(c *gin.Context) {
 		return
 	}
 
-	var res TakeOrderResponse
-	res.Status = models.StatusSuccess
+	var res UpdateOrderResponse
+	res.Status = ResultSuccess
 
 	c.JSON(http.StatusOK, res)
 }
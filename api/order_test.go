@@ -2,8 +2,9 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	mocket "github.com/selvatico/go-mocket"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"io/ioutil"
@@ -15,7 +16,10 @@ import (
 	"order-service/models"
 	"order-service/pkgs/e"
 	"order-service/services/distance"
+	"order-service/services/geocoding"
+	"strings"
 	"testing"
+	"time"
 )
 
 // helper function to parse json
@@ -43,19 +47,82 @@ func createJson(i interface{}) (io.Reader, error) {
 	return bytes.NewBuffer(body), err
 }
 
+// courier taking the orders in the tests
+const testCourierID = "courier-1"
+
+// error from the repository which fails every call
+var errBrokenRepository = errors.New("the repository is broken")
+
+// repository which fails every call to test the internal errors
+type brokenRepository struct{}
+
+func (brokenRepository) Create(context.Context, *models.Order) error { return errBrokenRepository }
+func (brokenRepository) Get(context.Context, int64) (*models.Order, error) {
+	return nil, errBrokenRepository
+}
+func (brokenRepository) List(context.Context, models.OrderFilter, int, int) ([]*models.Order, error) {
+	return nil, errBrokenRepository
+}
+func (brokenRepository) Take(context.Context, int64, string) error { return errBrokenRepository }
+func (brokenRepository) Release(context.Context, int64, string) error {
+	return errBrokenRepository
+}
+func (brokenRepository) UpdateStatus(context.Context, int64, string, string, string) error {
+	return errBrokenRepository
+}
+func (brokenRepository) Cancel(context.Context, int64, string, models.Cancellation) error {
+	return errBrokenRepository
+}
+func (brokenRepository) CountByStatus(context.Context) (map[string]int64, error) {
+	return nil, errBrokenRepository
+}
+
+// helper function to store an order with the status in the repository
+func createOrder(t *testing.T, repo models.OrderRepository, status string) *models.Order {
+	o := models.Order{Status: models.StatusUnassigned, Distance: rand.Intn(5000)}
+	if err := repo.Create(context.Background(), &o); err != nil {
+		t.Fatal(err)
+	}
+
+	if status == models.StatusTaken {
+		if err := repo.Take(context.Background(), o.ID, testCourierID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := repo.Get(context.Background(), o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stored
+}
+
+// helper function to create the expected json
+func toJson(t *testing.T, i interface{}) string {
+	body, err := json.Marshal(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
 // test success from create order
 func TestCreateOrder(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
 
 	// init the mock calculator
 	var expectDistance = rand.Intn(5000)
-	distance.InitMockCalculator(expectDistance, nil)
+	calc := distance.NewMockCalculator(expectDistance, nil)
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, calc, nil))
 
 	// create request body
 	var createOrder requests.CreateOrderRequest
@@ -79,21 +146,234 @@ func TestCreateOrder(t *testing.T) {
 	a.Nil(err, "should not error out upon parsing error")
 	a.NotNil(orderResponse, "server should have return the order")
 	a.Equal(expectDistance, orderResponse.Distance, "server should return the correct distance")
+	a.Equal(distance.ProviderMock, orderResponse.DistanceProvider, "server should return the provider of the distance")
+	a.Nil(orderResponse.DurationSeconds, "server should not return a duration the provider does not know")
+	a.Equal(distance.ModeDriving, orderResponse.Mode, "server should default the mode to driving")
 	a.Equal(models.StatusUnassigned, orderResponse.Status, "server should create order with default UNASSIGNED")
+	a.Equal(35.9984617, *orderResponse.Origin.Lat, "server should return the origin latitude")
+	a.Equal(-115.0980736, *orderResponse.Destination.Lng, "server should return the destination longitude")
+	a.False(orderResponse.CreatedAt.IsZero(), "server should return the creation time")
+	a.Nil(orderResponse.TakenAt, "server should not return a taken time")
+}
+
+// test for create order return the travel time of the route
+func TestCreateOrder_Duration(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// init the mock calculator with a travel time
+	calc := distance.NewMockResultCalculator(distance.Result{
+		Distance: 6412,
+		Duration: 540300 * time.Millisecond,
+		Provider: distance.ProviderMock,
+		Status:   "OK",
+	})
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, calc, nil))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	// parse the response
+	var orderResponse models.Order
+	err = parseJson(w.Body, &orderResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	if a.NotNil(orderResponse.DurationSeconds, "server should return the duration") {
+		a.Equal(540, *orderResponse.DurationSeconds, "duration should be rounded to seconds")
+	}
+
+	// check the duration is stored
+	stored, _ := repo.Get(context.Background(), orderResponse.ID)
+	if a.NotNil(stored.DurationSeconds, "duration should be stored") {
+		a.Equal(540, *stored.DurationSeconds, "stored duration should match")
+	}
 }
 
 // test for error response from create order with unknown distance
 func TestCreateOrder_Unknown_Distance(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// init the mock calculator
+	calc := distance.NewMockCalculator(0, e.ErrDistanceUnknown)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, calc, nil))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.NotNil(errorResponse, "server should have error response")
+	a.Equal(e.ErrDistanceUnknown.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test for error response from create order when the distance lookup times out
+func TestCreateOrder_Timeout(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// init the mock calculator
+	calc := distance.NewMockCalculator(0, e.ErrTimeout)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, calc, nil))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusGatewayTimeout, w.Code, "server should return back 504 Gateway Timeout")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal(e.ErrTimeout.Error(), errorResponse.Error, "error response should match the error content")
+
+	// check the order is not stored
+	orders, _ := repo.List(context.Background(), models.OrderFilter{}, 0, 10)
+	a.Equal(0, len(orders), "order should not be stored")
+}
+
+// calculator which never answers until the caller gives up
+type hangingCalculator struct{}
+
+func (hangingCalculator) Calculate(ctx context.Context, src []string, des []string, opts distance.Options) (distance.Result, error) {
+	<-ctx.Done()
+	return distance.Result{}, ctx.Err()
+}
+
+// test for error response from create order with a provider which hangs behind the timeout and the retries
+func TestCreateOrder_Provider_Timeout(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// the same decorators as a remote provider has
+	opts := distance.ResilienceOptions{MaxRetries: 1, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	calc := distance.NewResilientCalculator(distance.NewTimeoutCalculator(hangingCalculator{}, 5*time.Millisecond), "test", opts)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), calc, nil))
+
+	reqBody, err := createJson(requests.CreateOrderRequest{
+		Origin:      []string{"35.9984617", "-115.1432558"},
+		Destination: []string{"36.0222811", "-115.0980736"},
+	})
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code and the error
+	a.Equal(http.StatusGatewayTimeout, w.Code, "server should return back 504 Gateway Timeout")
+	a.JSONEq(toJson(t, e.CreateErr(e.ErrTimeout)), w.Body.String(), "error response should match the error content")
+}
+
+// test for error response from create order when the distance provider is down
+func TestCreateOrder_Distance_Unavailable(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
 
 	// init the mock calculator
-	distance.InitMockCalculator(0, e.ErrDistanceUnknown)
+	calc := distance.NewMockCalculator(0, e.ErrDistanceUnavailable)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, calc, nil))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusServiceUnavailable, w.Code, "server should return back 503 Service Unavailable")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal(e.ErrDistanceUnavailable.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test for create order stop when the client has gone away
+func TestCreateOrder_Canceled(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(100, nil), nil))
 
 	// create request body
 	var createOrder requests.CreateOrderRequest
@@ -103,6 +383,213 @@ func TestCreateOrder_Unknown_Distance(t *testing.T) {
 
 	a.Nil(err, "should not have problem with create json")
 
+	// make request with a context canceled like a closed connection
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req.WithContext(ctx))
+
+	// check response code
+	a.Equal(order.StatusClientClosedRequest, w.Code, "server should return back 499 Client Closed Request")
+
+	// check the order is not stored
+	orders, _ := repo.List(context.Background(), models.OrderFilter{}, 0, 10)
+	a.Equal(0, len(orders), "order should not be stored")
+}
+
+// test for error response from create order with bad request
+func TestCreateOrder_Bad_Request(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// init the mock calculator
+	var expectDistance = rand.Intn(5000)
+	calc := distance.NewMockCalculator(expectDistance, nil)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, calc, nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", nil)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err := parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.NotNil(errorResponse, "server should have error response")
+	a.Equal(e.ErrOrderRequestInvalid.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test for error response from create order with invalid coordinates
+func TestCreateOrder_Invalid_Coordinates(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// init the mock calculator
+	calc := distance.NewMockCalculator(rand.Intn(5000), nil)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, calc, nil))
+
+	cases := []struct {
+		origin        []string
+		originAddress string
+		destination   []string
+		expect        []e.FieldError
+	}{
+		{
+			origin:      nil,
+			destination: []string{"36.0222811", "-115.0980736"},
+			expect:      []e.FieldError{{Field: "origin", Reason: "is required"}},
+		},
+		{
+			origin:      []string{"foo"},
+			destination: []string{"36.0222811", "-115.0980736"},
+			expect:      []e.FieldError{{Field: "origin", Reason: "must have exactly 2 elements [lat, lng]"}},
+		},
+		{
+			origin:      []string{"35.9984617", "-115.1432558"},
+			destination: []string{"999", "999"},
+			expect: []e.FieldError{
+				{Field: "destination[0]", Reason: "latitude 999 must be between -90 and 90"},
+				{Field: "destination[1]", Reason: "longitude 999 must be between -180 and 180"},
+			},
+		},
+		{
+			origin:      []string{"35.9984617", "bar"},
+			destination: []string{"36.0222811", "-115.0980736"},
+			expect:      []e.FieldError{{Field: "origin[1]", Reason: "longitude must be a number"}},
+		},
+		{
+			origin:      []string{"NaN", "1"},
+			destination: []string{"1", "Inf"},
+			expect: []e.FieldError{
+				{Field: "origin[0]", Reason: "latitude must be a number"},
+				{Field: "destination[1]", Reason: "longitude must be a number"},
+			},
+		},
+		{
+			origin:        []string{"35.9984617", "-115.1432558"},
+			originAddress: "Las Vegas",
+			destination:   []string{"36.0222811", "-115.0980736"},
+			expect:        []e.FieldError{{Field: "origin", Reason: "can not be set together with origin_address"}},
+		},
+		{
+			originAddress: "   ",
+			destination:   []string{"36.0222811", "-115.0980736"},
+			expect:        []e.FieldError{{Field: "origin_address", Reason: "must not be blank"}},
+		},
+		{
+			originAddress: strings.Repeat("a", 257),
+			destination:   []string{"36.0222811", "-115.0980736"},
+			expect:        []e.FieldError{{Field: "origin_address", Reason: "must be at most 256 characters"}},
+		},
+	}
+
+	for _, tc := range cases {
+		// create request body
+		var createOrder requests.CreateOrderRequest
+		createOrder.Origin = tc.origin
+		createOrder.OriginAddress = tc.originAddress
+		createOrder.Destination = tc.destination
+		reqBody, err := createJson(createOrder)
+
+		a.Nil(err, "should not have problem with create json")
+
+		// make request to recorder
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+		r.ServeHTTP(w, req)
+
+		// check response code
+		a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+		// parsing the error response
+		var errorResponse e.ResponseError
+		err = parseJson(w.Body, &errorResponse)
+		a.Nil(err, "should not error out upon parsing error")
+		a.Equal(e.ErrOrderRequestValidation.Error(), errorResponse.Error, "error response should match the error content")
+		a.Equal(tc.expect, errorResponse.Fields, "error response should list the invalid fields")
+	}
+}
+
+// test for create order with the addresses resolved by the geocoder
+func TestCreateOrder_Address(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router with the fake geocoder which knows both addresses
+	geocoder := geocoding.NewFakeGeocoder(map[string]geocoding.Place{
+		"The Strip": {Lat: 36.1146, Lng: -115.1728, FormattedAddress: "Las Vegas Strip, Las Vegas, NV, USA"},
+		"Henderson": {Lat: 36.0395, Lng: -114.9817, FormattedAddress: "Henderson, NV, USA"},
+	})
+	r := InitRouter(models.NewOrderService(repo, distance.NewHaversineCalculator(1), geocoder))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.OriginAddress = "the strip"
+	createOrder.DestinationAddress = "Henderson"
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	// parsing the order response
+	var orderResponse models.Order
+	err = parseJson(w.Body, &orderResponse)
+	a.Nil(err, "should not error out upon parsing order")
+	a.Equal("Las Vegas Strip, Las Vegas, NV, USA", orderResponse.Origin.Address, "server should return the formatted origin address")
+	a.Equal(36.1146, *orderResponse.Origin.Lat, "server should return the origin latitude of the address")
+	a.Equal("Henderson, NV, USA", orderResponse.Destination.Address, "server should return the formatted destination address")
+	a.Equal(-114.9817, *orderResponse.Destination.Lng, "server should return the destination longitude of the address")
+	a.True(orderResponse.Distance > 0, "server should return the distance between the addresses")
+}
+
+// test for create order with an address the geocoder does not know
+func TestCreateOrder_Address_Not_Found(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router with the fake geocoder which knows nothing
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(100, nil), geocoding.NewFakeGeocoder(nil)))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.DestinationAddress = "Atlantis"
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
 	// make request to recorder
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
@@ -115,376 +602,1442 @@ func TestCreateOrder_Unknown_Distance(t *testing.T) {
 	var errorResponse e.ResponseError
 	err = parseJson(w.Body, &errorResponse)
 	a.Nil(err, "should not error out upon parsing error")
-	a.NotNil(errorResponse, "server should have error response")
-	a.Equal(e.ErrDistanceUnknown.Error(), errorResponse.Error, "error response should match the error content")
+	a.Equal(e.ErrAddressNotFound.Error(), errorResponse.Error, "error response should match the error content")
+	a.Equal([]e.FieldError{{Field: "destination_address", Reason: "could not be found"}}, errorResponse.Fields, "error response should name the address")
+}
+
+// test for create order with an address while the service has no geocoder
+func TestCreateOrder_Geocoding_Disabled(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router without a geocoder
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(100, nil), nil))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.OriginAddress = "Las Vegas"
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code and the error
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	a.JSONEq(toJson(t, e.CreateErr(e.ErrGeocodingDisabled)), w.Body.String(), "error response should match the error content")
+}
+
+// test for create order with stops between the origin and the destination
+func TestCreateOrder_Stops(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router with the fake geocoder for the address of the stop
+	geocoder := geocoding.NewFakeGeocoder(map[string]geocoding.Place{
+		"Henderson": {Lat: 36.0395, Lng: -114.9817, FormattedAddress: "Henderson, NV, USA"},
+	})
+	r := InitRouter(models.NewOrderService(repo, distance.NewHaversineCalculator(1), geocoder))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Stops = []requests.StopRequest{
+		{Address: "Henderson"},
+		{Location: []string{"36.1146", "-115.1728"}},
+	}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	// parsing the order response
+	var orderResponse models.Order
+	err = parseJson(w.Body, &orderResponse)
+	a.Nil(err, "should not error out upon parsing order")
+	if a.Len(orderResponse.Stops, 2, "server should return the stops") {
+		a.Equal("Henderson, NV, USA", orderResponse.Stops[0].Location.Address, "server should return the address of the stop")
+		a.Equal(36.1146, *orderResponse.Stops[1].Location.Lat, "server should return the coordinate of the stop")
+	}
+	if a.Len(orderResponse.Legs, 3, "server should return a leg to every stop and the destination") {
+		var sum int
+		for i, leg := range orderResponse.Legs {
+			a.Equal(i, leg.Sequence, "server should return the legs in the sequence")
+			a.True(leg.Distance > 0, "server should return the distance of the leg")
+			sum += leg.Distance
+		}
+		a.Equal(sum, orderResponse.Distance, "server should return the total distance of the legs")
+	}
+
+	// the order is stored with its stops
+	stored, _ := repo.Get(context.Background(), orderResponse.ID)
+	a.Len(stored.Stops, 2, "stops should be stored")
+}
+
+// test for create order with invalid stops
+func TestCreateOrder_Invalid_Stops(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(100, nil), nil))
+
+	cases := []struct {
+		stops  []requests.StopRequest
+		expect []e.FieldError
+	}{
+		{
+			stops:  []requests.StopRequest{{}},
+			expect: []e.FieldError{{Field: "stops[0].location", Reason: "is required"}},
+		},
+		{
+			stops:  []requests.StopRequest{{Location: []string{"36.1146", "-115.1728"}}, {Location: []string{"foo", "-115.1728"}}},
+			expect: []e.FieldError{{Field: "stops[1].location[0]", Reason: "latitude must be a number"}},
+		},
+		{
+			stops:  []requests.StopRequest{{Location: []string{"36.1146", "-115.1728"}, Address: "Henderson"}},
+			expect: []e.FieldError{{Field: "stops[0].location", Reason: "can not be set together with stops[0].address"}},
+		},
+		{
+			stops:  make([]requests.StopRequest, 9),
+			expect: []e.FieldError{{Field: "stops", Reason: "must have at most 8 stops"}},
+		},
+	}
+
+	for _, tc := range cases {
+		// create request body
+		var createOrder requests.CreateOrderRequest
+		createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+		createOrder.Stops = tc.stops
+		createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+		reqBody, err := createJson(createOrder)
+
+		a.Nil(err, "should not have problem with create json")
+
+		// make request to recorder
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+		r.ServeHTTP(w, req)
+
+		// check response code
+		a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+		// parsing the error response
+		var errorResponse e.ResponseError
+		err = parseJson(w.Body, &errorResponse)
+		a.Nil(err, "should not error out upon parsing error")
+		a.Equal(tc.expect, errorResponse.Fields, "error response should list the invalid fields")
+	}
+}
+
+// test for create order with a stop address the geocoder does not know
+func TestCreateOrder_Stop_Address_Not_Found(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router with the fake geocoder which knows nothing
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(100, nil), geocoding.NewFakeGeocoder(nil)))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Stops = []requests.StopRequest{{Location: []string{"36.1146", "-115.1728"}}, {Address: "Atlantis"}}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code and the error
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	expect := e.CreateValidationErr(e.ErrAddressNotFound, []e.FieldError{{Field: "stops[1].address", Reason: "could not be found"}})
+	a.JSONEq(toJson(t, expect), w.Body.String(), "error response should name the address of the stop")
+}
+
+// test for create order with the routing options of a walking courier
+func TestCreateOrder_Mode(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router with the offline calculator which knows the walking mode
+	r := InitRouter(models.NewOrderService(repo, distance.NewHaversineCalculator(1), nil))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	createOrder.Mode = distance.ModeWalking
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	// parse the response
+	var orderResponse models.Order
+	err = parseJson(w.Body, &orderResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal(distance.ModeWalking, orderResponse.Mode, "server should return the mode")
+
+	// check the mode is stored
+	stored, _ := repo.Get(context.Background(), orderResponse.ID)
+	a.Equal(distance.ModeWalking, stored.Mode, "mode should be stored")
+}
+
+// test for error response from create order with unknown routing options
+func TestCreateOrder_Invalid_Options(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(rand.Intn(5000), nil), nil))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	createOrder.Mode = "flying"
+	createOrder.Avoid = []string{"tolls", "potholes", "tolls"}
+	yesterday := time.Now().Add(-24 * time.Hour)
+	createOrder.DepartureTime = &yesterday
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal(e.ErrOrderRequestValidation.Error(), errorResponse.Error, "error response should match the error content")
+	a.Equal([]e.FieldError{
+		{Field: "mode", Reason: "must be one of driving, walking, bicycling, transit"},
+		{Field: "avoid[1]", Reason: "must be one of tolls, highways, ferries"},
+		{Field: "avoid[2]", Reason: "tolls is repeated"},
+		{Field: "departure_time", Reason: "must not be in the past"},
+	}, errorResponse.Fields, "error response should list the invalid fields")
+}
+
+// test for create order with a departure time in the past only by transit
+func TestCreateOrder_Departure_Time_Transit(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(rand.Intn(5000), nil), nil))
+
+	yesterday := time.Now().Add(-24 * time.Hour)
+	for mode, code := range map[string]int{distance.ModeTransit: http.StatusOK, distance.ModeDriving: http.StatusBadRequest, "": http.StatusBadRequest} {
+		reqBody, err := createJson(requests.CreateOrderRequest{
+			Origin:        []string{"35.9984617", "-115.1432558"},
+			Destination:   []string{"36.0222811", "-115.0980736"},
+			Mode:          mode,
+			DepartureTime: &yesterday,
+		})
+		a.Nil(err, "should not have problem with create json")
+
+		// make request to recorder
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+		r.ServeHTTP(w, req)
+
+		a.Equal(code, w.Code, "server should return back %d for a past departure by %q", code, mode)
+	}
+}
+
+// test for error response from create order with options the provider can not route
+func TestCreateOrder_Unsupported_Options(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router with the offline calculator which has no timetable
+	r := InitRouter(models.NewOrderService(repo, distance.NewHaversineCalculator(1), nil))
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	createOrder.Mode = distance.ModeTransit
+	reqBody, err := createJson(createOrder)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal(e.ErrRouteOptionsUnsupported.Error(), errorResponse.Error, "error response should match the error content")
+	a.Equal([]e.FieldError{
+		{Field: "mode", Reason: "transit is not supported by the haversine provider"},
+	}, errorResponse.Fields, "error response should name the unsupported option")
+
+	// check the order is not stored
+	orders, _ := repo.List(context.Background(), models.OrderFilter{}, 0, 10)
+	a.Equal(0, len(orders), "order should not be stored")
+}
+
+// test for error response from create order with internal error
+func TestCreateOrder_Internal_Sever_Error(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the repository which fails every call
+	repo := brokenRepository{}
+
+	// init the mock calculator
+	var expectDistance = rand.Intn(5000)
+	calc := distance.NewMockCalculator(expectDistance, nil)
+
+	// create request body
+	var createOrder requests.CreateOrderRequest
+	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
+	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
+	reqBody, err := createJson(createOrder)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, calc, nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusInternalServerError, w.Code, "server should return back 500 Internal Sever Error")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.NotNil(errorResponse, "server should have error response")
+	a.Equal(e.ErrInternalError.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test success from get orders
+func TestGetOrders(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	order1 := createOrder(t, repo, models.StatusUnassigned)
+	order2 := createOrder(t, repo, models.StatusTaken)
+	orders := []*models.Order{order1, order2}
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+
+	// create the query string
+	q := req.URL.Query()
+	q.Add("page", "0")
+	q.Add("limit", "2")
+	req.URL.RawQuery = q.Encode()
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	// check the response
+	a.JSONEq(toJson(t, orders), w.Body.String(), "orders should match exactly")
+}
+
+// test for get orders of a single courier
+func TestGetOrders_Assignee(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	createOrder(t, repo, models.StatusUnassigned)
+	taken := createOrder(t, repo, models.StatusTaken)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders?page=0&limit=10&assignee_id="+testCourierID, nil)
+	r.ServeHTTP(w, req)
+
+	// check response code and the orders of the courier
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	a.JSONEq(toJson(t, []*models.Order{taken}), w.Body.String(), "only the orders of the courier should be listed")
+}
+
+// test for get orders with the filters and the sort
+func TestGetOrders_Filter(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	var orders []*models.Order
+	for _, d := range []int{300, 100, 200} {
+		o := models.Order{Status: models.StatusUnassigned, Distance: d}
+		if err := repo.Create(context.Background(), &o); err != nil {
+			t.Fatal(err)
+		}
+		orders = append(orders, &o)
+	}
+	createOrder(t, repo, models.StatusTaken)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+
+	// create the query string
+	q := req.URL.Query()
+	q.Add("limit", "10")
+	q.Add("status", models.StatusUnassigned)
+	q.Add("min_distance", "150")
+	q.Add("created_after", orders[0].CreatedAt.Add(-time.Minute).Format(time.RFC3339))
+	q.Add("sort", models.SortDistance)
+	req.URL.RawQuery = q.Encode()
+	r.ServeHTTP(w, req)
+
+	// check response code and the orders
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	a.JSONEq(toJson(t, []*models.Order{orders[2], orders[0]}), w.Body.String(), "orders should be filtered and sorted")
+}
+
+// test for error response from get orders with unknown filters and sort
+func TestGetOrders_Invalid_Filter(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+
+	// create the query string
+	q := req.URL.Query()
+	q.Add("status", "LOST")
+	q.Add("min_distance", "500")
+	q.Add("max_distance", "100")
+	q.Add("created_after", "yesterday")
+	q.Add("sort", "distance; DROP TABLE orders")
+	req.URL.RawQuery = q.Encode()
+	r.ServeHTTP(w, req)
+
+	// check response code and the error
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	expect := e.CreateValidationErr(e.ErrQueryStringInvalid, []e.FieldError{
+		{Field: "status", Reason: "must be one of " + strings.Join(models.Statuses, ", ")},
+		{Field: "max_distance", Reason: "must not be less than min_distance"},
+		{Field: "created_after", Reason: "must be an RFC 3339 time"},
+		{Field: "sort", Reason: "must be one of " + strings.Join(models.Sorts, ", ")},
+	})
+	a.JSONEq(toJson(t, expect), w.Body.String(), "error response should list the invalid fields")
+}
+
+// helper function to get the orders with the query string
+func getOrders(r http.Handler, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders?"+query, nil)
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+// test for get orders by the cursor page after page in an envelope
+func TestGetOrders_Cursor(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	order1 := createOrder(t, repo, models.StatusUnassigned)
+	order2 := createOrder(t, repo, models.StatusUnassigned)
+	order3 := createOrder(t, repo, models.StatusTaken)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// an empty cursor starts at the first page
+	w := getOrders(r, "cursor=&limit=2")
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	var page order.OrdersPage
+	a.Nil(parseJson(w.Body, &page), "should not error out upon parsing response")
+	a.JSONEq(toJson(t, []*models.Order{order1, order2}), toJson(t, page.Orders), "first page should have the oldest orders")
+	if !a.NotNil(page.NextCursor, "first page should have a next cursor") {
+		return
+	}
+
+	// the order created meanwhile ends up on the next page
+	order4 := createOrder(t, repo, models.StatusUnassigned)
+
+	w = getOrders(r, "limit=2&cursor="+*page.NextCursor)
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	expect := order.OrdersPage{Orders: []*models.Order{order3, order4}}
+	a.JSONEq(toJson(t, expect), w.Body.String(), "last page should have the rest and a null cursor")
+}
+
+// test for error response from get orders with a cursor which can not be used
+func TestGetOrders_Invalid_Cursor(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil))
+
+	w := getOrders(r, "cursor=abc&page=1&sort=distance")
+
+	// check response code and the error
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	expect := e.CreateValidationErr(e.ErrQueryStringInvalid, []e.FieldError{
+		{Field: "page", Reason: "can not be used with a cursor"},
+		{Field: "limit", Reason: "must be positive with a cursor"},
+		{Field: "sort", Reason: "must be created_at or -created_at with a cursor"},
+		{Field: "cursor", Reason: "is not a valid cursor"},
+	})
+	a.JSONEq(toJson(t, expect), w.Body.String(), "error response should list the invalid fields")
+}
+
+// test for error response from get orders with no query
+func TestGetOrders_No_Query(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+	r.ServeHTTP(w, req)
+
+	// it should return 200 since default value would 0, 0
+	// check response code
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	// parsing the response
+	var ordersResponse []models.Order
+	err := parseJson(w.Body, &ordersResponse)
+	a.Nil(err, "should not error out upon parsing response")
+	a.NotNil(ordersResponse, "server should have a response")
+	a.Equal(0, len(ordersResponse), "response should be an empty array")
+}
+
+// test for error response from get orders with invalid query type
+func TestGetOrders_Invalid_Query_Type(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+
+	// create the query string
+	q := req.URL.Query()
+	q.Add("page", "test")
+	q.Add("limit", "1")
+	req.URL.RawQuery = q.Encode()
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err := parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.NotNil(errorResponse, "server should have error response")
+	a.Equal(e.ErrQueryStringInvalid.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test for error response from get orders with no query value
+func TestGetOrders_Invalid_Query_Value(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+
+	// create the query string
+	q := req.URL.Query()
+	q.Add("page", "-1")
+	q.Add("limit", "1")
+	req.URL.RawQuery = q.Encode()
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err := parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.NotNil(errorResponse, "server should have error response")
+	a.Equal(e.ErrQueryStringInvalid.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test for error response from get orders with internal error
+func TestGetOrders_Internal_Server_Error(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the repository which fails every call
+	repo := brokenRepository{}
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+
+	// create the query string
+	q := req.URL.Query()
+	q.Add("page", "0")
+	q.Add("limit", "2")
+	req.URL.RawQuery = q.Encode()
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusInternalServerError, w.Code, "server should return back 500 Internal Sever Error")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err := parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.NotNil(errorResponse, "server should have error response")
+	a.Equal(e.ErrInternalError.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test success from get order
+func TestGetOrder(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	order1 := createOrder(t, repo, models.StatusUnassigned)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders/1", nil)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	// check the response
+	a.JSONEq(toJson(t, order1), w.Body.String(), "order should match exactly")
+}
+
+// test for error response from get order with order not found
+func TestGetOrder_Not_Found(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders/1", nil)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusNotFound, w.Code, "server should return back 404 Not Found")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err := parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal(e.ErrOrderNotExist.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test for error response from get order with id is invalid form
+func TestGetOrder_Invalid_ID(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/orders/testtest", nil)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err := parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal(e.ErrOrderRequestInvalid.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test success from take order
+func TestTakeOrder(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	createOrder(t, repo, models.StatusUnassigned)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// create request body
+	var takeOrderRequest requests.UpdateOrderRequest
+	takeOrderRequest.Status = models.StatusTaken
+	takeOrderRequest.CourierID = testCourierID
+	reqBody, err := createJson(takeOrderRequest)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+
+	// parsing response
+	var taken models.Order
+	err = parseJson(w.Body, &taken)
+	a.Nil(err, "should not error out upon parsing response")
+	a.Equal(models.StatusTaken, taken.Status, "response should be the taken order")
+	a.Equal(testCourierID, taken.AssigneeID, "response should have the assignee")
+	if a.NotNil(taken.TakenAt, "response should have the taken time") {
+		a.WithinDuration(time.Now(), *taken.TakenAt, 5*time.Second, "taken time should be the time of the request")
+	}
+}
+
+// test for error response from take order with order already taken
+func TestTakeOrder_Already_Taken(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	createOrder(t, repo, models.StatusTaken)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// create request body
+	var takeOrderRequest requests.UpdateOrderRequest
+	takeOrderRequest.Status = models.StatusTaken
+	takeOrderRequest.CourierID = testCourierID
+	reqBody, err := createJson(takeOrderRequest)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusConflict, w.Code, "server should return back 409 Conflict")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.NotNil(errorResponse, "server should have error response")
+	a.Equal(e.ErrOrderAlreadyTaken.Error(), errorResponse.Error, "error response should match the error content")
+}
+
+// test for update order through the statuses after taking it
+func TestUpdateOrder(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	o := createOrder(t, repo, models.StatusTaken)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	for _, status := range []string{models.StatusPickedUp, models.StatusDelivered} {
+		// create request body
+		var updateOrderRequest requests.UpdateOrderRequest
+		updateOrderRequest.Status = status
+		updateOrderRequest.CourierID = testCourierID
+		reqBody, err := createJson(updateOrderRequest)
+
+		a.Nil(err, "should not have problem with create json")
+
+		// make request to recorder
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+		r.ServeHTTP(w, req)
+
+		// check response code
+		a.Equal(http.StatusOK, w.Code, "server should return back 200 OK for %s", status)
+		stored, _ := repo.Get(context.Background(), o.ID)
+		a.JSONEq(toJson(t, stored), w.Body.String(), "response should be the updated order")
+	}
+
+	stored, _ := repo.Get(context.Background(), o.ID)
+	a.Equal(models.StatusDelivered, stored.Status, "order should be delivered")
+	a.NotNil(stored.DeliveredAt, "delivery time should be set")
+}
+
+// test for error response from update order taken by another courier
+func TestUpdateOrder_Not_Assignee(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	o := createOrder(t, repo, models.StatusTaken)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	for _, status := range []string{models.StatusPickedUp, models.StatusFailed} {
+		reqBody, err := createJson(requests.UpdateOrderRequest{Status: status, CourierID: "courier-2"})
+		a.Nil(err, "should not have problem with create json")
+
+		// make request to recorder
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+		r.ServeHTTP(w, req)
+
+		// check response code and the error
+		a.Equal(http.StatusForbidden, w.Code, "server should return back 403 Forbidden for %s", status)
+		a.JSONEq(toJson(t, e.CreateErr(e.ErrOrderNotAssignee)), w.Body.String(), "error response should match the error content")
+	}
+
+	stored, _ := repo.Get(context.Background(), o.ID)
+	a.Equal(models.StatusTaken, stored.Status, "status should not change")
+}
+
+// test for error response from update order with a transition which is not allowed
+func TestUpdateOrder_Illegal_Transition(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	createOrder(t, repo, models.StatusUnassigned)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// create request body
+	var updateOrderRequest requests.UpdateOrderRequest
+	updateOrderRequest.Status = models.StatusDelivered
+	updateOrderRequest.CourierID = testCourierID
+	reqBody, err := createJson(updateOrderRequest)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusConflict, w.Code, "server should return back 409 Conflict")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal("the order can not move from UNASSIGNED to DELIVERED", errorResponse.Error, "error response should name the statuses")
+	a.Equal(models.StatusUnassigned, errorResponse.CurrentStatus, "error response should contain the current status")
+	a.Equal(models.StatusDelivered, errorResponse.RequestedStatus, "error response should contain the requested status")
+}
+
+// test for error response from update order with a status the orders do not know
+func TestUpdateOrder_Unknown_Status(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil))
+
+	// SUCCESS is the result of an update, not a status
+	var updateOrderRequest requests.UpdateOrderRequest
+	updateOrderRequest.Status = order.ResultSuccess
+	reqBody, err := createJson(updateOrderRequest)
+
+	a.Nil(err, "should not have problem with create json")
+
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	r.ServeHTTP(w, req)
+
+	// check response code
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+
+	// parsing the error response
+	var errorResponse e.ResponseError
+	err = parseJson(w.Body, &errorResponse)
+	a.Nil(err, "should not error out upon parsing error")
+	a.Equal(e.ErrOrderRequestInvalid.Error(), errorResponse.Error, "error response should match the error content")
+	reason := "must be one of UNASSIGNED, TAKEN, PICKED_UP, DELIVERED, CANCELLED, FAILED"
+	a.Equal([]e.FieldError{{Field: "status", Reason: reason}}, errorResponse.Fields, "error response should list the statuses")
 }
 
-// test for error response from create order with bad request
-func TestCreateOrder_Bad_Request(t *testing.T) {
+// helper function to send the cancel request of the order
+func cancelOrder(r http.Handler, id string, body requests.CancelOrderRequest) *httptest.ResponseRecorder {
+	reqBody, _ := createJson(body)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders/"+id+"/cancel", reqBody)
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+// test for cancel order with the reason
+func TestCancelOrder(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
 
-	// init the mock calculator
-	var expectDistance = rand.Intn(5000)
-	distance.InitMockCalculator(expectDistance, nil)
+	// set up expected result
+	o := createOrder(t, repo, models.StatusTaken)
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
-	// make request to recorder
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodPost, "/orders", nil)
-	r.ServeHTTP(w, req)
+	w := cancelOrder(r, "1", requests.CancelOrderRequest{Reason: models.CancelReasonCustomerRequest, Note: "not hungry anymore", CancelledBy: "support-42"})
 
 	// check response code
-	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	a.JSONEq(toJson(t, order.UpdateOrderResponse{Status: order.ResultSuccess}), w.Body.String(), "response should contain SUCCESS")
 
-	// parsing the error response
-	var errorResponse e.ResponseError
-	err := parseJson(w.Body, &errorResponse)
-	a.Nil(err, "should not error out upon parsing error")
-	a.NotNil(errorResponse, "server should have error response")
-	a.Equal(e.ErrOrderRequestInvalid.Error(), errorResponse.Error, "error response should match the error content")
+	stored, _ := repo.Get(context.Background(), o.ID)
+	a.Equal(models.StatusCancelled, stored.Status, "order should be cancelled")
+	a.Equal("support-42", stored.CancelledBy, "who cancelled should be stored")
+	a.Equal(models.CancelReasonCustomerRequest, stored.CancelReason, "reason should be stored")
+	a.Equal("not hungry anymore", stored.CancelNote, "note should be stored")
 }
 
-// test for error response from create order with internal error
-func TestCreateOrder_Internal_Sever_Error(t *testing.T) {
+// test for error response from cancel order which is already over
+func TestCancelOrder_Already_Over(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	cases := []struct {
+		status string
+		expect *e.ResponseError
+	}{
+		{models.StatusCancelled, e.CreateErr(e.ErrOrderAlreadyCancelled)},
+		{models.StatusDelivered, e.CreateErr(e.ErrOrderAlreadyDelivered)},
+		{models.StatusPickedUp, e.CreateTransitionErr(&e.TransitionError{Current: models.StatusPickedUp, Requested: models.StatusCancelled})},
+	}
 
-	// init the mock calculator
-	var expectDistance = rand.Intn(5000)
-	distance.InitMockCalculator(expectDistance, nil)
+	for _, tc := range cases {
+		// init the in-memory repository with the order in the status
+		repo := models.NewMemoryOrderRepository()
+		o := models.Order{Status: tc.status}
+		if err := repo.Create(context.Background(), &o); err != nil {
+			t.Fatal(err)
+		}
 
-	// mock the query that create order with exception
-	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "orders" ("distance","status") VALUES (?,?)`).WithExecException()
-	defer mocket.Catcher.Reset()
+		// get the router
+		r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
-	// create request body
-	var createOrder requests.CreateOrderRequest
-	createOrder.Origin = []string{"35.9984617", "-115.1432558"}
-	createOrder.Destination = []string{"36.0222811", "-115.0980736"}
-	reqBody, err := createJson(createOrder)
+		w := cancelOrder(r, "1", requests.CancelOrderRequest{Reason: models.CancelReasonDuplicate, CancelledBy: "support-42"})
+
+		// check response code and the error
+		a.Equal(http.StatusConflict, w.Code, "server should return back 409 Conflict for %s", tc.status)
+		a.JSONEq(toJson(t, tc.expect), w.Body.String(), "error response should explain the %s order", tc.status)
+	}
+}
+
+// test for error response from cancel order with an invalid request
+func TestCancelOrder_Invalid_Request(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil))
+
+	cases := []struct {
+		req    requests.CancelOrderRequest
+		expect []e.FieldError
+	}{
+		{
+			req: requests.CancelOrderRequest{Reason: "BORED", CancelledBy: "support-42"},
+			expect: []e.FieldError{{
+				Field:  "reason",
+				Reason: "must be one of CUSTOMER_REQUEST, COURIER_UNAVAILABLE, ADDRESS_INVALID, DUPLICATE, OTHER",
+			}},
+		},
+		{
+			req:    requests.CancelOrderRequest{Reason: models.CancelReasonOther, CancelledBy: "support-42"},
+			expect: []e.FieldError{{Field: "note", Reason: "is required for the reason OTHER"}},
+		},
+		{
+			req:    requests.CancelOrderRequest{Reason: models.CancelReasonDuplicate, Note: strings.Repeat("a", 501), CancelledBy: "support-42"},
+			expect: []e.FieldError{{Field: "note", Reason: "must be at most 500 characters"}},
+		},
+		{
+			req:    requests.CancelOrderRequest{Reason: models.CancelReasonDuplicate, CancelledBy: "  "},
+			expect: []e.FieldError{{Field: "cancelled_by", Reason: "is required"}},
+		},
+	}
 
-	// make request to recorder
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodPost, "/orders", reqBody)
-	r.ServeHTTP(w, req)
+	for _, tc := range cases {
+		w := cancelOrder(r, "1", tc.req)
 
-	// check response code
-	a.Equal(http.StatusInternalServerError, w.Code, "server should return back 500 Internal Sever Error")
+		// check response code
+		a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
 
-	// parsing the error response
-	var errorResponse e.ResponseError
-	err = parseJson(w.Body, &errorResponse)
-	a.Nil(err, "should not error out upon parsing error")
-	a.NotNil(errorResponse, "server should have error response")
-	a.Equal(e.ErrInternalError.Error(), errorResponse.Error, "error response should match the error content")
+		// parsing the error response
+		var errorResponse e.ResponseError
+		err := parseJson(w.Body, &errorResponse)
+		a.Nil(err, "should not error out upon parsing error")
+		a.Equal(e.ErrOrderRequestValidation.Error(), errorResponse.Error, "error response should match the error content")
+		a.Equal(tc.expect, errorResponse.Fields, "error response should list the invalid fields")
+	}
 }
 
-// test success from get orders
-func TestGetOrders(t *testing.T) {
+// test for error response from cancel order with order not found
+func TestCancelOrder_Not_Found(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil))
+
+	w := cancelOrder(r, "1", requests.CancelOrderRequest{Reason: models.CancelReasonDuplicate, CancelledBy: "support-42"})
 
-	// set up expected result
-	order1 := models.Order{ID: 1, Status: models.StatusUnassigned, Distance: rand.Intn(5000)}
-	order2 := models.Order{ID: 2, Status: models.StatusTaken, Distance: rand.Intn(5000)}
-	orders := []models.Order{order1, order2}
+	// check response code and the error
+	a.Equal(http.StatusNotFound, w.Code, "server should return back 404 Not Found")
+	a.JSONEq(toJson(t, e.CreateErr(e.ErrOrderNotExist)), w.Body.String(), "error response should match the error content")
+}
 
-	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
+// test for error response from update order to cancelled without a reason
+func TestUpdateOrder_Cancel(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
 
-	// mock the query that query the orders
-	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"   LIMIT 2 OFFSET 0`).WithReply(expectMap)
-	defer mocket.Catcher.Reset()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	createOrder(t, repo, models.StatusUnassigned)
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	// create request body
+	reqBody, err := createJson(requests.UpdateOrderRequest{Status: models.StatusCancelled})
+	a.Nil(err, "should not have problem with create json")
 
 	// make request to recorder
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
-
-	// create the query string
-	q := req.URL.Query()
-	q.Add("page", "0")
-	q.Add("limit", "2")
-	req.URL.RawQuery = q.Encode()
+	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
 	r.ServeHTTP(w, req)
 
-	// check response code
-	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	// check response code and the error
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	expect := e.CreateValidationErr(e.ErrOrderRequestInvalid, []e.FieldError{{Field: "status", Reason: "use POST /orders/:id/cancel to cancel the order"}})
+	a.JSONEq(toJson(t, expect), w.Body.String(), "error response should point to the cancel route")
+}
 
-	// parsing the response
-	var ordersResponse []models.Order
-	err := parseJson(w.Body, &ordersResponse)
-	a.Nil(err, "should not error out upon parsing response")
-	a.NotNil(ordersResponse, "server should have a response")
-	a.Equal(len(orders), len(ordersResponse), "response should return 2 orders")
-	a.Equal(order1, ordersResponse[0], "order 1 should match exactly")
-	a.Equal(order2, ordersResponse[1], "order 2 should match exactly")
+// helper function to send the release request of the order
+func releaseOrder(r http.Handler, id string, courierID string) *httptest.ResponseRecorder {
+	reqBody, _ := createJson(requests.ReleaseOrderRequest{CourierID: courierID})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/orders/"+id+"/release", reqBody)
+	r.ServeHTTP(w, req)
+
+	return w
 }
 
-// test for error response from get orders with no query
-func TestGetOrders_No_Query(t *testing.T) {
+// test for release order by the courier who took it
+func TestReleaseOrder(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+
+	// set up expected result
+	o := createOrder(t, repo, models.StatusTaken)
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
-	// make request to recorder
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
-	r.ServeHTTP(w, req)
+	w := releaseOrder(r, "1", testCourierID)
 
-	// it should return 200 since default value would 0, 0
 	// check response code
 	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	a.JSONEq(toJson(t, order.UpdateOrderResponse{Status: order.ResultSuccess}), w.Body.String(), "response should contain SUCCESS")
 
-	// parsing the response
-	var ordersResponse []models.Order
-	err := parseJson(w.Body, &ordersResponse)
-	a.Nil(err, "should not error out upon parsing response")
-	a.NotNil(ordersResponse, "server should have a response")
-	a.Equal(0, len(ordersResponse), "response should be an empty array")
+	stored, _ := repo.Get(context.Background(), o.ID)
+	a.Equal(models.StatusUnassigned, stored.Status, "order should be back in the pool")
+	a.Len(stored.Releases, 1, "release should be recorded")
 }
 
-// test for error response from get orders with invalid query type
-func TestGetOrders_Invalid_Query_Type(t *testing.T) {
+// test for error response from release order by another courier
+func TestReleaseOrder_Not_Assignee(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	createOrder(t, repo, models.StatusTaken)
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
-	// make request to recorder
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+	w := releaseOrder(r, "1", "courier-2")
 
-	// create the query string
-	q := req.URL.Query()
-	q.Add("page", "test")
-	q.Add("limit", "1")
-	req.URL.RawQuery = q.Encode()
-	r.ServeHTTP(w, req)
+	// check response code and the error
+	a.Equal(http.StatusForbidden, w.Code, "server should return back 403 Forbidden")
+	a.JSONEq(toJson(t, e.CreateErr(e.ErrOrderNotAssignee)), w.Body.String(), "error response should match the error content")
+}
 
-	// check response code
-	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+// test for error response from release order which is not taken
+func TestReleaseOrder_Not_Taken(t *testing.T) {
+	t.Parallel()
 
-	// parsing the error response
-	var errorResponse e.ResponseError
-	err := parseJson(w.Body, &errorResponse)
-	a.Nil(err, "should not error out upon parsing error")
-	a.NotNil(errorResponse, "server should have error response")
-	a.Equal(e.ErrQueryStringInvalid.Error(), errorResponse.Error, "error response should match the error content")
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	createOrder(t, repo, models.StatusUnassigned)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	w := releaseOrder(r, "1", testCourierID)
+
+	// check response code and the error
+	a.Equal(http.StatusConflict, w.Code, "server should return back 409 Conflict")
+	expect := e.CreateTransitionErr(&e.TransitionError{Current: models.StatusUnassigned, Requested: models.StatusUnassigned})
+	a.JSONEq(toJson(t, expect), w.Body.String(), "error response should name the statuses")
 }
 
-// test for error response from get orders with no query value
-func TestGetOrders_Invalid_Query_Value(t *testing.T) {
+// test for error response from release order without the courier
+func TestReleaseOrder_No_Courier(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil))
+
+	w := releaseOrder(r, "1", "")
+
+	// check response code and the error
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	expect := e.CreateValidationErr(e.ErrOrderRequestValidation, []e.FieldError{{Field: "courier_id", Reason: "is required"}})
+	a.JSONEq(toJson(t, expect), w.Body.String(), "error response should list the invalid fields")
+}
+
+// test for take order by the courier of the header
+func TestTakeOrder_Courier_Header(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	o := createOrder(t, repo, models.StatusUnassigned)
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
+
+	reqBody, err := createJson(requests.UpdateOrderRequest{Status: models.StatusTaken})
+	a.Nil(err, "should not have problem with create json")
 
 	// make request to recorder
 	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
-
-	// create the query string
-	q := req.URL.Query()
-	q.Add("page", "-1")
-	q.Add("limit", "1")
-	req.URL.RawQuery = q.Encode()
+	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	req.Header.Set(order.CourierHeader, "courier-2")
 	r.ServeHTTP(w, req)
 
-	// check response code
-	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
-
-	// parsing the error response
-	var errorResponse e.ResponseError
-	err := parseJson(w.Body, &errorResponse)
-	a.Nil(err, "should not error out upon parsing error")
-	a.NotNil(errorResponse, "server should have error response")
-	a.Equal(e.ErrQueryStringInvalid.Error(), errorResponse.Error, "error response should match the error content")
+	// check response code and the assignee
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	stored, _ := repo.Get(context.Background(), o.ID)
+	a.Equal("courier-2", stored.AssigneeID, "courier of the header should be the assignee")
 }
 
-// test for error response from get orders with internal error
-func TestGetOrders_Internal_Server_Error(t *testing.T) {
-	a := assert.New(t)
+// test for take order trim the courier so the courier can list the order
+func TestTakeOrder_Courier_Trimmed(t *testing.T) {
+	t.Parallel()
 
-	// init the mock database
-	models.InitMockModel()
+	a := assert.New(t)
 
-	// mock the query that query the orders
-	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"   LIMIT 2 OFFSET 0`).WithQueryException()
-	defer mocket.Catcher.Reset()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	o := createOrder(t, repo, models.StatusUnassigned)
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
-	// make request to recorder
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodGet, "/orders", nil)
+	reqBody, err := createJson(requests.UpdateOrderRequest{Status: models.StatusTaken, CourierID: " courier-2 "})
+	a.Nil(err, "should not have problem with create json")
 
-	// create the query string
-	q := req.URL.Query()
-	q.Add("page", "0")
-	q.Add("limit", "2")
-	req.URL.RawQuery = q.Encode()
+	// make request to recorder, the header only differs by the spaces
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	req.Header.Set(order.CourierHeader, "courier-2")
 	r.ServeHTTP(w, req)
 
-	// check response code
-	a.Equal(http.StatusInternalServerError, w.Code, "server should return back 500 Internal Sever Error")
+	// check response code and the assignee
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	stored, _ := repo.Get(context.Background(), o.ID)
+	a.Equal("courier-2", stored.AssigneeID, "assignee should be trimmed")
 
-	// parsing the error response
-	var errorResponse e.ResponseError
-	err := parseJson(w.Body, &errorResponse)
-	a.Nil(err, "should not error out upon parsing error")
-	a.NotNil(errorResponse, "server should have error response")
-	a.Equal(e.ErrInternalError.Error(), errorResponse.Error, "error response should match the error content")
-}
+	// the courier lists the order
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/orders?page=0&limit=10&assignee_id=courier-2", nil)
+	r.ServeHTTP(w, req)
 
-// test success from take order
-func TestTakeOrder(t *testing.T) {
-	a := assert.New(t)
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+	a.Contains(w.Body.String(), `"assignee_id":"courier-2"`, "order should be listed for the courier")
 
-	// init the mock database
-	models.InitMockModel()
+	// and moves it on with the spaces again
+	reqBody, _ = createJson(requests.UpdateOrderRequest{Status: models.StatusPickedUp, CourierID: " courier-2 "})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	r.ServeHTTP(w, req)
 
-	// set up expected result
-	const orderId = 1
-	order1 := models.Order{ID: orderId, Status: models.StatusUnassigned, Distance: rand.Intn(5000)}
-	orders := []models.Order{order1}
+	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
+}
 
-	// get the router
-	r := InitRouter()
+// test for error response from take order for another courier than the header
+func TestTakeOrder_Courier_Mismatch(t *testing.T) {
+	t.Parallel()
 
-	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
+	a := assert.New(t)
 
-	// mock the query that get the order by id
-	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`).WithReply(expectMap)
-	defer mocket.Catcher.Reset()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	createOrder(t, repo, models.StatusUnassigned)
 
-	// create request body
-	var takeOrderRequest requests.TakeOrderRequest
-	takeOrderRequest.Status = models.StatusTaken
-	reqBody, err := createJson(takeOrderRequest)
+	// get the router
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
+	reqBody, err := createJson(requests.UpdateOrderRequest{Status: models.StatusTaken, CourierID: testCourierID})
 	a.Nil(err, "should not have problem with create json")
 
 	// make request to recorder
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	req.Header.Set(order.CourierHeader, "courier-2")
 	r.ServeHTTP(w, req)
 
-	// check response code
-	a.Equal(http.StatusOK, w.Code, "server should return back 200 OK")
-
-	// parsing response
-	var takeOrderResponse order.TakeOrderResponse
-	err = parseJson(w.Body, &takeOrderResponse)
-	a.Nil(err, "should not error out upon parsing response")
-	a.NotNil(takeOrderRequest, "server should have a response")
-	a.Equal(models.StatusSuccess, takeOrderResponse.Status, "response should contain SUCCESS")
+	// check response code and the error
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	a.JSONEq(toJson(t, e.CreateErr(e.ErrCourierMismatch)), w.Body.String(), "error response should match the error content")
 }
 
-// test for error response from take order with order already taken
-func TestTakeOrder_Already_Taken(t *testing.T) {
+// test for error response from take order without the courier
+func TestTakeOrder_No_Courier(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// get the router
+	r := InitRouter(models.NewOrderService(models.NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil))
 
-	// set up expected result
-	const orderId = 1
-	order1 := models.Order{ID: orderId, Status: models.StatusTaken, Distance: rand.Intn(5000)}
-	orders := []models.Order{order1}
+	reqBody, err := createJson(requests.UpdateOrderRequest{Status: models.StatusTaken})
+	a.Nil(err, "should not have problem with create json")
 
-	// get the router
-	r := InitRouter()
+	// make request to recorder
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
+	r.ServeHTTP(w, req)
 
-	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
+	// check response code and the error
+	a.Equal(http.StatusBadRequest, w.Code, "server should return back 400 Bad Request")
+	expect := e.CreateValidationErr(e.ErrOrderRequestInvalid, []e.FieldError{{Field: "courier_id", Reason: "is required"}})
+	a.JSONEq(toJson(t, expect), w.Body.String(), "error response should list the invalid fields")
+}
 
-	// mock the query that get the order by id
-	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`).WithReply(expectMap)
-	defer mocket.Catcher.Reset()
+// test for error response from take order the courier has just released
+func TestTakeOrder_Release_Cooldown(t *testing.T) {
+	t.Parallel()
 
-	// create request body
-	var takeOrderRequest requests.TakeOrderRequest
-	takeOrderRequest.Status = models.StatusTaken
-	reqBody, err := createJson(takeOrderRequest)
+	a := assert.New(t)
+
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
+	createOrder(t, repo, models.StatusTaken)
 
+	// get the router with the cooldown
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil).WithReleaseCooldown(time.Hour))
+
+	a.Equal(http.StatusOK, releaseOrder(r, "1", testCourierID).Code, "server should release the order")
+
+	// take the order again
+	reqBody, err := createJson(requests.UpdateOrderRequest{Status: models.StatusTaken, CourierID: testCourierID})
 	a.Nil(err, "should not have problem with create json")
 
-	// make request to recorder
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest(http.MethodPatch, "/orders/1", reqBody)
 	r.ServeHTTP(w, req)
 
-	// check response code
+	// check response code and the error
 	a.Equal(http.StatusConflict, w.Code, "server should return back 409 Conflict")
-
-	// parsing the error response
-	var errorResponse e.ResponseError
-	err = parseJson(w.Body, &errorResponse)
-	a.Nil(err, "should not error out upon parsing error")
-	a.NotNil(errorResponse, "server should have error response")
-	a.Equal(e.ErrOrderAlreadyTaken.Error(), errorResponse.Error, "error response should match the error content")
+	a.JSONEq(toJson(t, e.CreateErr(e.ErrOrderReleaseCooldown)), w.Body.String(), "error response should match the error content")
 }
 
 // test for error response from take order with order not found
 func TestTakeOrder_Not_Found(t *testing.T) {
-	a := assert.New(t)
+	t.Parallel()
 
-	// init the mock database
-	models.InitMockModel()
+	a := assert.New(t)
 
-	// set up expected result
-	const orderId = 1
-	order1 := models.Order{ID: orderId, Status: models.StatusTaken, Distance: rand.Intn(5000)}
-	orders := []models.Order{order1}
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
 
 	// get the router
-	r := InitRouter()
-
-	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
-
-	// mock the query that get the order by id
-	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`)
-	defer mocket.Catcher.Reset()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
 	// create request body
-	var takeOrderRequest requests.TakeOrderRequest
+	var takeOrderRequest requests.UpdateOrderRequest
 	takeOrderRequest.Status = models.StatusTaken
+	takeOrderRequest.CourierID = testCourierID
 	reqBody, err := createJson(takeOrderRequest)
 
 	a.Nil(err, "should not have problem with create json")
@@ -507,13 +2060,15 @@ func TestTakeOrder_Not_Found(t *testing.T) {
 
 // test for error response from take order with no id provided
 func TestTakeOrder_No_ID(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
 	// make request to recorder
 	w := httptest.NewRecorder()
@@ -526,13 +2081,15 @@ func TestTakeOrder_No_ID(t *testing.T) {
 
 // test for error response from take order with id is invalid form
 func TestTakeOrder_Invalid_ID(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
 	// make request to recorder
 	w := httptest.NewRecorder()
@@ -552,16 +2109,18 @@ func TestTakeOrder_Invalid_ID(t *testing.T) {
 
 // test for error response from take order with invalid request body
 func TestTakeOrder_Invalid_Request(t *testing.T) {
+	t.Parallel()
+
 	a := assert.New(t)
 
-	// init the mock database
-	models.InitMockModel()
+	// init the in-memory repository
+	repo := models.NewMemoryOrderRepository()
 
 	// get the router
-	r := InitRouter()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
 	// create request body
-	var takeOrderRequest requests.TakeOrderRequest
+	var takeOrderRequest requests.UpdateOrderRequest
 	takeOrderRequest.Status = "test"
 	reqBody, err := createJson(takeOrderRequest)
 
@@ -585,31 +2144,20 @@ func TestTakeOrder_Invalid_Request(t *testing.T) {
 
 // test for error response from take order with internal server error
 func TestTakeOrder_Internal_Server_Error(t *testing.T) {
-	a := assert.New(t)
+	t.Parallel()
 
-	// init the mock database
-	models.InitMockModel()
+	a := assert.New(t)
 
-	// set up expected result
-	const orderId = 1
-	order1 := models.Order{ID: orderId, Status: models.StatusTaken, Distance: rand.Intn(5000)}
-	orders := []models.Order{order1}
+	// init the repository which fails every call
+	repo := brokenRepository{}
 
 	// get the router
-	r := InitRouter()
-
-	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
-
-	// mock the query that get the order by id
-	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`).WithQueryException()
-	defer mocket.Catcher.Reset()
+	r := InitRouter(models.NewOrderService(repo, distance.NewMockCalculator(0, nil), nil))
 
 	// create request body
-	var takeOrderRequest requests.TakeOrderRequest
+	var takeOrderRequest requests.UpdateOrderRequest
 	takeOrderRequest.Status = models.StatusTaken
+	takeOrderRequest.CourierID = testCourierID
 	reqBody, err := createJson(takeOrderRequest)
 
 	a.Nil(err, "should not have problem with create json")
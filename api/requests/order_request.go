@@ -1,19 +1,373 @@
 package requests
 
+import (
+	"fmt"
+	"math"
+	"order-service/models"
+	"order-service/pkgs/e"
+	"order-service/services/distance"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// max length of a free-form address
+const maxAddressLength = 256
+
+// max number of stops between the origin and the destination
+const maxStops = 8
+
+// max length of the note on a cancellation
+const maxCancelNoteLength = 500
+
+// max length of the name of who cancelled the order
+const maxCancelledByLength = 64
+
 // struct for create order request body
+// Note: every point is given either as a [lat, lng] pair or as an address to geocode
 type CreateOrderRequest struct {
-	Origin      []string `json:"origin"`
-	Destination []string `json:"destination"`
+	Origin             []string      `json:"origin"`
+	OriginAddress      string        `json:"origin_address"`
+	Stops              []StopRequest `json:"stops"`
+	Destination        []string      `json:"destination"`
+	DestinationAddress string        `json:"destination_address"`
+	Mode               string        `json:"mode"`
+	Avoid              []string      `json:"avoid"`
+	DepartureTime      *time.Time    `json:"departure_time"`
 }
 
+// struct for a stop between the origin and the destination, in the order of the route
+type StopRequest struct {
+	Location []string `json:"location"`
+	Address  string   `json:"address"`
+}
 
-// struct for get order query strings
+// validate the points and the routing options before they reach the geocoder and the distance provider
+func (r CreateOrderRequest) Validate() []e.FieldError {
+	var fields []e.FieldError
+	fields = append(fields, validateEndpoint("origin", "origin_address", r.Origin, r.OriginAddress)...)
+	fields = append(fields, r.validateStops()...)
+	fields = append(fields, validateEndpoint("destination", "destination_address", r.Destination, r.DestinationAddress)...)
+	fields = append(fields, r.validateOptions()...)
+
+	return fields
+}
+
+// return the routing options of the request
+func (r CreateOrderRequest) Options() distance.Options {
+	opts := distance.Options{Mode: r.Mode, Avoid: r.Avoid}
+	if r.DepartureTime != nil {
+		opts.DepartureTime = *r.DepartureTime
+	}
+
+	return opts
+}
+
+// validate every stop the same as the origin and the destination
+func (r CreateOrderRequest) validateStops() []e.FieldError {
+	if len(r.Stops) > maxStops {
+		return []e.FieldError{{Field: "stops", Reason: fmt.Sprintf("must have at most %d stops", maxStops)}}
+	}
+
+	var fields []e.FieldError
+	for i, stop := range r.Stops {
+		name := fmt.Sprintf("stops[%d]", i)
+		fields = append(fields, validateEndpoint(name+".location", name+".address", stop.Location, stop.Address)...)
+	}
+
+	return fields
+}
+
+// validate the mode and the features to avoid against the known values and the departure time against now
+func (r CreateOrderRequest) validateOptions() []e.FieldError {
+	var fields []e.FieldError
+
+	if r.Mode != "" && !contains(distance.Modes, r.Mode) {
+		reason := "must be one of " + strings.Join(distance.Modes, ", ")
+		fields = append(fields, e.FieldError{Field: "mode", Reason: reason})
+	}
+
+	seen := make(map[string]bool, len(r.Avoid))
+	for i, avoid := range r.Avoid {
+		name := fmt.Sprintf("avoid[%d]", i)
+		switch {
+		case !contains(distance.Avoids, avoid):
+			reason := "must be one of " + strings.Join(distance.Avoids, ", ")
+			fields = append(fields, e.FieldError{Field: name, Reason: reason})
+		case seen[avoid]:
+			fields = append(fields, e.FieldError{Field: name, Reason: fmt.Sprintf("%s is repeated", avoid)})
+		}
+		seen[avoid] = true
+	}
+
+	// the providers only know the traffic ahead, the timetable of transit is known for the past as well
+	if r.DepartureTime != nil && r.Mode != distance.ModeTransit && r.DepartureTime.Before(time.Now()) {
+		fields = append(fields, e.FieldError{Field: "departure_time", Reason: "must not be in the past"})
+	}
+
+	return fields
+}
+
+// check if the value is in the list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate a point given either as a [lat, lng] pair or as an address
+func validateEndpoint(name string, field string, c []string, address string) []e.FieldError {
+	if address == "" {
+		return validateCoordinate(name, c)
+	}
+
+	if c != nil {
+		return []e.FieldError{{Field: name, Reason: fmt.Sprintf("can not be set together with %s", field)}}
+	}
+
+	if strings.TrimSpace(address) == "" {
+		return []e.FieldError{{Field: field, Reason: "must not be blank"}}
+	}
+
+	if len(address) > maxAddressLength {
+		return []e.FieldError{{Field: field, Reason: fmt.Sprintf("must be at most %d characters", maxAddressLength)}}
+	}
+
+	return nil
+}
+
+// validate a [lat, lng] pair of strings
+func validateCoordinate(name string, c []string) []e.FieldError {
+	if c == nil {
+		return []e.FieldError{{Field: name, Reason: "is required"}}
+	}
+
+	if len(c) != 2 {
+		return []e.FieldError{{Field: name, Reason: "must have exactly 2 elements [lat, lng]"}}
+	}
+
+	var fields []e.FieldError
+
+	lat, err := strconv.ParseFloat(c[0], 64)
+	// NaN passes every comparison of the range and can not be written as json
+	if err != nil || math.IsNaN(lat) || math.IsInf(lat, 0) {
+		fields = append(fields, e.FieldError{Field: name + "[0]", Reason: "latitude must be a number"})
+	} else if lat < -90 || lat > 90 {
+		fields = append(fields, e.FieldError{Field: name + "[0]", Reason: fmt.Sprintf("latitude %v must be between -90 and 90", c[0])})
+	}
+
+	lng, err := strconv.ParseFloat(c[1], 64)
+	if err != nil || math.IsNaN(lng) || math.IsInf(lng, 0) {
+		fields = append(fields, e.FieldError{Field: name + "[1]", Reason: "longitude must be a number"})
+	} else if lng < -180 || lng > 180 {
+		fields = append(fields, e.FieldError{Field: name + "[1]", Reason: fmt.Sprintf("longitude %v must be between -180 and 180", c[1])})
+	}
+
+	return fields
+}
+
+// struct for get order query strings, paged either by the page or by the cursor
+// Note: the creation times are RFC 3339 like the departure time of a new order
 type GetOrderRequest struct {
-	Page  int `form:"page"`
-	Limit int `form:"limit"`
+	Page          int    `form:"page"`
+	Limit         int    `form:"limit"`
+	Status        string `form:"status"`
+	AssigneeID    string `form:"assignee_id"`
+	MinDistance   *int   `form:"min_distance"`
+	MaxDistance   *int   `form:"max_distance"`
+	CreatedAfter  string `form:"created_after"`
+	CreatedBefore string `form:"created_before"`
+	Sort          string `form:"sort"`
+	Cursor        string `form:"cursor"`
+}
+
+// validate the filters and the sort against the known values
+func (r GetOrderRequest) Validate() []e.FieldError {
+	var fields []e.FieldError
+
+	if r.Status != "" && !contains(models.Statuses, r.Status) {
+		reason := "must be one of " + strings.Join(models.Statuses, ", ")
+		fields = append(fields, e.FieldError{Field: "status", Reason: reason})
+	}
+
+	if r.MinDistance != nil && *r.MinDistance < 0 {
+		fields = append(fields, e.FieldError{Field: "min_distance", Reason: "must not be negative"})
+	}
+	if r.MaxDistance != nil && *r.MaxDistance < 0 {
+		fields = append(fields, e.FieldError{Field: "max_distance", Reason: "must not be negative"})
+	}
+	if r.MinDistance != nil && r.MaxDistance != nil && *r.MinDistance > *r.MaxDistance {
+		fields = append(fields, e.FieldError{Field: "max_distance", Reason: "must not be less than min_distance"})
+	}
+
+	after, err := parseTime(r.CreatedAfter)
+	if err != nil {
+		fields = append(fields, e.FieldError{Field: "created_after", Reason: "must be an RFC 3339 time"})
+	}
+	before, err := parseTime(r.CreatedBefore)
+	if err != nil {
+		fields = append(fields, e.FieldError{Field: "created_before", Reason: "must be an RFC 3339 time"})
+	}
+	if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+		fields = append(fields, e.FieldError{Field: "created_before", Reason: "must be after created_after"})
+	}
+
+	if r.Sort != "" && !contains(models.Sorts, r.Sort) {
+		reason := "must be one of " + strings.Join(models.Sorts, ", ")
+		fields = append(fields, e.FieldError{Field: "sort", Reason: reason})
+	}
+
+	return fields
+}
+
+// the filter of the orders on the query strings, only for a valid request
+func (r GetOrderRequest) Filter() models.OrderFilter {
+	after, _ := parseTime(r.CreatedAfter)
+	before, _ := parseTime(r.CreatedBefore)
+
+	return models.OrderFilter{
+		Status:        r.Status,
+		AssigneeID:    strings.TrimSpace(r.AssigneeID),
+		MinDistance:   r.MinDistance,
+		MaxDistance:   r.MaxDistance,
+		CreatedAfter:  after,
+		CreatedBefore: before,
+		Sort:          r.Sort,
+	}
+}
+
+// validate the paging by the cursor, which replaces the page and only goes with the sorts by creation time
+// Note: the cursor is empty for the first page
+func (r GetOrderRequest) ValidateCursor() []e.FieldError {
+	var fields []e.FieldError
+
+	if r.Page != 0 {
+		fields = append(fields, e.FieldError{Field: "page", Reason: "can not be used with a cursor"})
+	}
+	if r.Limit <= 0 {
+		fields = append(fields, e.FieldError{Field: "limit", Reason: "must be positive with a cursor"})
+	}
+
+	// an unknown sort is already reported
+	if contains(models.Sorts, r.Sort) && r.Sort != models.SortCreatedAt && r.Sort != models.SortCreatedAtDesc {
+		reason := "must be " + models.SortCreatedAt + " or " + models.SortCreatedAtDesc + " with a cursor"
+		fields = append(fields, e.FieldError{Field: "sort", Reason: reason})
+	}
+
+	if r.Cursor != "" {
+		if _, err := models.DecodeCursor(r.Cursor); err != nil {
+			fields = append(fields, e.FieldError{Field: "cursor", Reason: "is not a valid cursor"})
+		}
+	}
+
+	return fields
+}
+
+// the filter of the orders after the cursor, sorted by creation time unless the sort is descending,
+// only for a valid request
+func (r GetOrderRequest) CursorFilter() models.OrderFilter {
+	filter := r.Filter()
+	if filter.Sort == "" {
+		filter.Sort = models.SortCreatedAt
+	}
+
+	if r.Cursor != "" {
+		after, _ := models.DecodeCursor(r.Cursor)
+		filter.After = &after
+	}
+
+	return filter
+}
+
+// function to parse an optional RFC 3339 time, zero when it is empty
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// struct for update order request body, the courier takes the order or is the assignee moving it on
+type UpdateOrderRequest struct {
+	Status    string `json:"status"`
+	CourierID string `json:"courier_id"`
+}
+
+// validate the status is one the orders know, the transition is checked against the order
+// Note: a cancellation needs a reason so it has its own route
+func (r UpdateOrderRequest) Validate() []e.FieldError {
+	if !contains(models.Statuses, r.Status) {
+		reason := "must be one of " + strings.Join(models.Statuses, ", ")
+		return []e.FieldError{{Field: "status", Reason: reason}}
+	}
+
+	if r.Status == models.StatusCancelled {
+		return []e.FieldError{{Field: "status", Reason: "use POST /orders/:id/cancel to cancel the order"}}
+	}
+
+	if r.Status == models.StatusUnassigned {
+		return []e.FieldError{{Field: "status", Reason: "use POST /orders/:id/release to release the order"}}
+	}
+
+	// the courier taking the order becomes its assignee, who is the only one to move it on
+	if strings.TrimSpace(r.CourierID) == "" {
+		return []e.FieldError{{Field: "courier_id", Reason: "is required"}}
+	}
+
+	return nil
+}
+
+// struct for release order request body
+type ReleaseOrderRequest struct {
+	CourierID string `json:"courier_id"`
+}
+
+// validate the courier who releases the order is present
+func (r ReleaseOrderRequest) Validate() []e.FieldError {
+	if strings.TrimSpace(r.CourierID) == "" {
+		return []e.FieldError{{Field: "courier_id", Reason: "is required"}}
+	}
+
+	return nil
+}
+
+// struct for cancel order request body
+type CancelOrderRequest struct {
+	Reason      string `json:"reason"`
+	Note        string `json:"note"`
+	CancelledBy string `json:"cancelled_by"`
+}
+
+// validate the reason code, the note is required to explain the other reason
+func (r CancelOrderRequest) Validate() []e.FieldError {
+	var fields []e.FieldError
+
+	if !contains(models.CancelReasons, r.Reason) {
+		reason := "must be one of " + strings.Join(models.CancelReasons, ", ")
+		fields = append(fields, e.FieldError{Field: "reason", Reason: reason})
+	}
+
+	if r.Reason == models.CancelReasonOther && strings.TrimSpace(r.Note) == "" {
+		fields = append(fields, e.FieldError{Field: "note", Reason: "is required for the reason OTHER"})
+	} else if len(r.Note) > maxCancelNoteLength {
+		fields = append(fields, e.FieldError{Field: "note", Reason: fmt.Sprintf("must be at most %d characters", maxCancelNoteLength)})
+	}
+
+	if strings.TrimSpace(r.CancelledBy) == "" {
+		fields = append(fields, e.FieldError{Field: "cancelled_by", Reason: "is required"})
+	} else if len(r.CancelledBy) > maxCancelledByLength {
+		fields = append(fields, e.FieldError{Field: "cancelled_by", Reason: fmt.Sprintf("must be at most %d characters", maxCancelledByLength)})
+	}
+
+	return fields
 }
 
-// struct for take order request body
-type TakeOrderRequest struct {
-	Status string `json:"status"`
+// return who cancelled the order and why
+func (r CancelOrderRequest) Cancellation() models.Cancellation {
+	return models.Cancellation{By: strings.TrimSpace(r.CancelledBy), Reason: r.Reason, Note: strings.TrimSpace(r.Note)}
 }
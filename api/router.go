@@ -2,25 +2,51 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"order-service/api/health"
+	"order-service/api/metrics"
 	"order-service/api/order"
+	"order-service/models"
 )
 
-// function for initialize the routes for gin
-func InitRouter() *gin.Engine {
+// function for initialize the routes for gin, the checks are run on readiness
+func InitRouter(svc *models.OrderService, checks ...health.Check) *gin.Engine {
 	r := gin.New()
+	h := order.NewHandler(svc)
+	hh := health.NewHandler(checks...)
+
+	// record every request below
+	r.Use(metrics.Middleware(r))
+
+	// metrics for prometheus
+	r.GET("/metrics", metrics.Handler())
+
+	// process is alive
+	r.GET("/healthz", hh.Healthz)
+
+	// dependencies are reachable
+	r.GET("/readyz", hh.Readyz)
 
 	orderRoute := r.Group(`/orders`)
 
 	orderRoute.Use()
 	{
 		// get orders
-		orderRoute.GET("", order.GetOrders)
+		orderRoute.GET("", h.GetOrders)
+
+		// get a single order
+		orderRoute.GET("/:id", h.GetOrder)
 
 		// update status of an order
-		orderRoute.PATCH("/:id", order.TakeOrder)
+		orderRoute.PATCH("/:id", h.UpdateOrder)
+
+		// cancel an order with the reason
+		orderRoute.POST("/:id/cancel", h.CancelOrder)
+
+		// give a taken order back to the pool
+		orderRoute.POST("/:id/release", h.ReleaseOrder)
 
 		// create a new order
-		orderRoute.POST("", order.CreateOrder)
+		orderRoute.POST("", h.CreateOrder)
 	}
 
 	return r
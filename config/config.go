@@ -3,15 +3,34 @@ package config
 import (
 	"fmt"
 	"github.com/spf13/viper"
+	"strings"
+	"time"
 )
 
 var config *Configuration
 
 const ConnectionStringFormat = "%s:%s@tcp(%s:%d)/%s?charset=utf8&parseTime=True&loc=Local"
 
+// schema of the service when MYSQL_SCHEMA is not set
+const DefaultSchema = "order-service"
+
 type Configuration struct {
-	MapConfig *MapConfiguration
-	DbConfig  *DbConfiguration
+	MapConfig      *MapConfiguration
+	OSRMConfig     *OSRMConfiguration
+	DbConfig       *DbConfiguration
+	DistanceConfig *DistanceConfiguration
+	GeocoderConfig *GeocoderConfiguration
+	OrderConfig    *OrderConfiguration
+	ServerConfig   *ServerConfiguration
+}
+
+type ServerConfiguration struct {
+	drainTimeout time.Duration
+}
+
+// return how long in-flight requests are waited for on shutdown
+func (s ServerConfiguration) GetDrainTimeout() time.Duration {
+	return s.drainTimeout
 }
 
 type MapConfiguration struct {
@@ -23,6 +42,126 @@ func (m MapConfiguration) GetMapApiKey() string {
 	return m.apiKey
 }
 
+type OSRMConfiguration struct {
+	baseURL string
+	profile string
+}
+
+// return the url of the osrm server
+func (o OSRMConfiguration) GetBaseURL() string {
+	return o.baseURL
+}
+
+// return the routing profile of the osrm server like driving
+func (o OSRMConfiguration) GetProfile() string {
+	return o.profile
+}
+
+type DistanceConfiguration struct {
+	providers        []string
+	roadFactor       float64
+	readyProbe       bool
+	cacheSize        int
+	cacheTTL         time.Duration
+	cacheNegativeTTL time.Duration
+	cachePrecision   int
+	timeout          time.Duration
+	lookupTimeout    time.Duration
+	maxRetries       int
+	retryBackoff     time.Duration
+	retryMaxBackoff  time.Duration
+	breakerThreshold int
+	breakerTimeout   time.Duration
+}
+
+// return the names of the distance providers in the order they are tried
+func (d DistanceConfiguration) GetProviders() []string {
+	return d.providers
+}
+
+// return the factor applied on straight-line distance to estimate the road distance
+func (d DistanceConfiguration) GetRoadFactor() float64 {
+	return d.roadFactor
+}
+
+// return if the readiness check should call the distance provider
+func (d DistanceConfiguration) GetReadyProbe() bool {
+	return d.readyProbe
+}
+
+// return the max number of cached distances, 0 disables the cache
+func (d DistanceConfiguration) GetCacheSize() int {
+	return d.cacheSize
+}
+
+// return how long a distance is cached
+func (d DistanceConfiguration) GetCacheTTL() time.Duration {
+	return d.cacheTTL
+}
+
+// return how long an unknown distance is cached
+func (d DistanceConfiguration) GetCacheNegativeTTL() time.Duration {
+	return d.cacheNegativeTTL
+}
+
+// return the decimals kept on the coordinates for the cache key
+func (d DistanceConfiguration) GetCachePrecision() int {
+	return d.cachePrecision
+}
+
+// return how long a single call to a provider may take, 0 disables the timeout
+func (d DistanceConfiguration) GetTimeout() time.Duration {
+	return d.timeout
+}
+
+// return how long the distance of an order may take with every retry and fallback, 0 disables the timeout
+func (d DistanceConfiguration) GetLookupTimeout() time.Duration {
+	return d.lookupTimeout
+}
+
+// return how many times a lookup failing with a transient error is retried
+func (d DistanceConfiguration) GetMaxRetries() int {
+	return d.maxRetries
+}
+
+// return the wait before the first retry, it doubles on every next one
+func (d DistanceConfiguration) GetRetryBackoff() time.Duration {
+	return d.retryBackoff
+}
+
+// return the max wait between the retries
+func (d DistanceConfiguration) GetRetryMaxBackoff() time.Duration {
+	return d.retryMaxBackoff
+}
+
+// return the failed lookups in a row which open the circuit, 0 disables the breaker
+func (d DistanceConfiguration) GetBreakerThreshold() int {
+	return d.breakerThreshold
+}
+
+// return how long the circuit stays open before the provider is tried again
+func (d DistanceConfiguration) GetBreakerOpenTimeout() time.Duration {
+	return d.breakerTimeout
+}
+
+type GeocoderConfiguration struct {
+	timeout time.Duration
+}
+
+// return how long resolving a single address may take, 0 disables the timeout
+func (g GeocoderConfiguration) GetTimeout() time.Duration {
+	return g.timeout
+}
+
+type OrderConfiguration struct {
+	releaseCooldown time.Duration
+}
+
+// return how long a courier has to wait to take again the order they released, 0 disables it
+func (o OrderConfiguration) GetReleaseCooldown() time.Duration {
+	return o.releaseCooldown
+}
+
 type DbConfiguration struct {
 	hostname   string
 	port       int
@@ -31,11 +170,28 @@ type DbConfiguration struct {
 	schemaName string
 }
 
+// return the schema the service runs on
+func (d DbConfiguration) GetSchemaName() string {
+	return d.schemaName
+}
+
 // return the whole connection string
 func (d DbConfiguration) GetConnectionString() string {
 	return fmt.Sprintf(ConnectionStringFormat, d.username, d.password, d.hostname, d.port, d.schemaName)
 }
 
+// function to split a comma separated value, the empty items are dropped
+func parseList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
 // getter of the config var
 func GetConfig() *Configuration {
 	return config
@@ -49,8 +205,26 @@ func InitConfig() {
 	v.SetConfigType("json")
 
 	// default values
-	v.SetDefault("MYSQL_SCHEMA", "order-service")
+	v.SetDefault("MYSQL_SCHEMA", DefaultSchema)
 	v.SetDefault("MYSQL_PORT", 3306)
+	v.SetDefault("DISTANCE_PROVIDER", "google")
+	v.SetDefault("OSRM_PROFILE", "driving")
+	v.SetDefault("DISTANCE_ROAD_FACTOR", 1.0)
+	v.SetDefault("DISTANCE_READY_PROBE", false)
+	v.SetDefault("DISTANCE_CACHE_SIZE", 10000)
+	v.SetDefault("DISTANCE_CACHE_TTL", "24h")
+	v.SetDefault("DISTANCE_CACHE_NEGATIVE_TTL", "10m")
+	v.SetDefault("DISTANCE_CACHE_PRECISION", 5)
+	v.SetDefault("DISTANCE_TIMEOUT", "5s")
+	v.SetDefault("DISTANCE_LOOKUP_TIMEOUT", "8s")
+	v.SetDefault("DISTANCE_MAX_RETRIES", 2)
+	v.SetDefault("DISTANCE_RETRY_BACKOFF", "100ms")
+	v.SetDefault("DISTANCE_RETRY_MAX_BACKOFF", "1s")
+	v.SetDefault("DISTANCE_BREAKER_THRESHOLD", 5)
+	v.SetDefault("DISTANCE_BREAKER_OPEN_TIMEOUT", "30s")
+	v.SetDefault("GEOCODER_TIMEOUT", "5s")
+	v.SetDefault("ORDER_RELEASE_COOLDOWN", "0s")
+	v.SetDefault("SHUTDOWN_DRAIN_TIMEOUT", "10s")
 
 	err := v.ReadInConfig()
 
@@ -59,7 +233,27 @@ func InitConfig() {
 		v.BindEnv("MYSQL_ROOT_PWD")
 		v.BindEnv("MYSQL_HOSTNAME")
 		v.BindEnv("MYSQL_USER")
+		v.BindEnv("MYSQL_SCHEMA")
 		v.BindEnv("MAP_API_KEY")
+		v.BindEnv("OSRM_BASE_URL")
+		v.BindEnv("OSRM_PROFILE")
+		v.BindEnv("DISTANCE_PROVIDER")
+		v.BindEnv("DISTANCE_ROAD_FACTOR")
+		v.BindEnv("DISTANCE_READY_PROBE")
+		v.BindEnv("DISTANCE_CACHE_SIZE")
+		v.BindEnv("DISTANCE_CACHE_TTL")
+		v.BindEnv("DISTANCE_CACHE_NEGATIVE_TTL")
+		v.BindEnv("DISTANCE_CACHE_PRECISION")
+		v.BindEnv("DISTANCE_TIMEOUT")
+		v.BindEnv("DISTANCE_LOOKUP_TIMEOUT")
+		v.BindEnv("DISTANCE_MAX_RETRIES")
+		v.BindEnv("DISTANCE_RETRY_BACKOFF")
+		v.BindEnv("DISTANCE_RETRY_MAX_BACKOFF")
+		v.BindEnv("DISTANCE_BREAKER_THRESHOLD")
+		v.BindEnv("DISTANCE_BREAKER_OPEN_TIMEOUT")
+		v.BindEnv("GEOCODER_TIMEOUT")
+		v.BindEnv("ORDER_RELEASE_COOLDOWN")
+		v.BindEnv("SHUTDOWN_DRAIN_TIMEOUT")
 	} else {
 		// overwrite if env is present
 		v.AutomaticEnv()
@@ -77,6 +271,40 @@ func InitConfig() {
 	var mapConfig MapConfiguration
 	mapConfig.apiKey = v.GetString("MAP_API_KEY")
 
+	var osrmConfig OSRMConfiguration
+	osrmConfig.baseURL = v.GetString("OSRM_BASE_URL")
+	osrmConfig.profile = v.GetString("OSRM_PROFILE")
+
+	var distanceConfig DistanceConfiguration
+	distanceConfig.providers = parseList(v.GetString("DISTANCE_PROVIDER"))
+	distanceConfig.roadFactor = v.GetFloat64("DISTANCE_ROAD_FACTOR")
+	distanceConfig.readyProbe = v.GetBool("DISTANCE_READY_PROBE")
+	distanceConfig.cacheSize = v.GetInt("DISTANCE_CACHE_SIZE")
+	distanceConfig.cacheTTL = v.GetDuration("DISTANCE_CACHE_TTL")
+	distanceConfig.cacheNegativeTTL = v.GetDuration("DISTANCE_CACHE_NEGATIVE_TTL")
+	distanceConfig.cachePrecision = v.GetInt("DISTANCE_CACHE_PRECISION")
+	distanceConfig.timeout = v.GetDuration("DISTANCE_TIMEOUT")
+	distanceConfig.lookupTimeout = v.GetDuration("DISTANCE_LOOKUP_TIMEOUT")
+	distanceConfig.maxRetries = v.GetInt("DISTANCE_MAX_RETRIES")
+	distanceConfig.retryBackoff = v.GetDuration("DISTANCE_RETRY_BACKOFF")
+	distanceConfig.retryMaxBackoff = v.GetDuration("DISTANCE_RETRY_MAX_BACKOFF")
+	distanceConfig.breakerThreshold = v.GetInt("DISTANCE_BREAKER_THRESHOLD")
+	distanceConfig.breakerTimeout = v.GetDuration("DISTANCE_BREAKER_OPEN_TIMEOUT")
+
+	var geocoderConfig GeocoderConfiguration
+	geocoderConfig.timeout = v.GetDuration("GEOCODER_TIMEOUT")
+
+	var orderConfig OrderConfiguration
+	orderConfig.releaseCooldown = v.GetDuration("ORDER_RELEASE_COOLDOWN")
+
+	var serverConfig ServerConfiguration
+	serverConfig.drainTimeout = v.GetDuration("SHUTDOWN_DRAIN_TIMEOUT")
+
 	config.DbConfig = &dbConfig
 	config.MapConfig = &mapConfig
+	config.OSRMConfig = &osrmConfig
+	config.DistanceConfig = &distanceConfig
+	config.GeocoderConfig = &geocoderConfig
+	config.OrderConfig = &orderConfig
+	config.ServerConfig = &serverConfig
 }
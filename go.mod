@@ -4,6 +4,7 @@ require (
 	github.com/gin-gonic/gin v1.4.0
 	github.com/google/uuid v1.1.1 // indirect
 	github.com/jinzhu/gorm v1.9.9
+	github.com/prometheus/client_golang v1.0.0
 	github.com/selvatico/go-mocket v1.0.7
 	github.com/sergi/go-diff v1.0.0 // indirect
 	github.com/sirupsen/logrus v1.4.2
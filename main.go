@@ -1,29 +1,98 @@
 package main
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"net/http"
 	"order-service/api"
+	"order-service/api/health"
+	"order-service/api/metrics"
 	"order-service/config"
 	"order-service/models"
 	"order-service/services/distance"
+	"order-service/services/geocoding"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
-func init() {
+func main() {
 	config.InitConfig()
-	distance.InitGoogleMapCalculator()
-	models.InitModel()
-}
+	c := config.GetConfig()
+
+	// init the distance calculator of the configured provider
+	provider, err := distance.NewProvider(c)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	calc := distance.WithCache(c, provider)
+
+	// init the geocoder for the orders given with addresses, without it only coordinates are accepted
+	geocoder, err := geocoding.NewGeocoder(c)
+	if err == geocoding.ErrNoApiKey {
+		logrus.Warn("MAP_API_KEY is not set, orders with addresses are rejected")
+	} else if err != nil {
+		logrus.Fatal(err)
+	}
+
+	// init the database connection
+	db, err := models.NewDB(c.DbConfig.GetConnectionString())
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	repo := models.NewInstrumentedOrderRepository(models.NewGormOrderRepository(db))
+	svc := models.NewOrderService(repo, calc, geocoder).
+		WithReleaseCooldown(c.OrderConfig.GetReleaseCooldown()).
+		WithLookupTimeout(c.DistanceConfig.GetLookupTimeout())
+
+	// count the orders by status on every scrape
+	prometheus.MustRegister(metrics.NewOrderStatusCollector(svc))
+
+	// check the db and optionally the distance provider on readiness, the probe skips the cache
+	checks := []health.Check{{Name: "mysql", Probe: db.DB().PingContext}}
+	if c.DistanceConfig.GetReadyProbe() {
+		checks = append(checks, health.Check{Name: "distance", Probe: distance.Probe(provider)})
+	}
+
+	// report the circuits of the providers, the service still serves the reads while one is open
+	for _, circuit := range distance.Circuits(provider) {
+		name := "distance_circuit_" + circuit.Provider()
+		checks = append(checks, health.Check{Name: name, Probe: circuit.Check, Optional: true})
+	}
 
-func main() {
 	// init the router
-	g := api.InitRouter()
+	g := api.InitRouter(svc, checks...)
 	g.Use(gin.Logger())
 	g.Use(gin.Recovery())
 
 	// run on 8080 for the server
-	err := g.Run(":8080")
-	if err != nil {
-		logrus.Fatal(err)
+	srv := &http.Server{Addr: ":8080", Handler: g}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Fatal(err)
+		}
+	}()
+
+	// wait for the signal from docker or ctrl+c
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	logrus.Infof("received %v, draining in-flight requests", sig)
+
+	// stop accepting new connections and wait for the in-flight requests
+	ctx, cancel := context.WithTimeout(context.Background(), c.ServerConfig.GetDrainTimeout())
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logrus.Error(err)
 	}
+
+	// nothing is using the db anymore
+	if err := db.Close(); err != nil {
+		logrus.Error(err)
+	}
+
+	logrus.Info("server stopped")
 }
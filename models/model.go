@@ -3,27 +3,27 @@ package models
 import (
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
-	"github.com/sirupsen/logrus"
-	"order-service/config"
+	"time"
 )
 
-var db *gorm.DB
-
-// initialize the client with mysql connection
-func InitModel() {
-	cString := config.GetConfig().DbConfig.GetConnectionString()
-
-	var err error
-	db, err = gorm.Open("mysql", cString)
+// open the mysql connection and migrate the tables
+func NewDB(cString string) (*gorm.DB, error) {
+	db, err := gorm.Open("mysql", cString)
 	if err != nil {
-		logrus.Fatal(err)
+		return nil, err
 	}
 
 	// migration on all the tables
-	migrate()
+	migrate(db)
+
+	return db, nil
 }
 
 // initialize the tables based on the model if not exist
-func migrate() {
-	db.AutoMigrate(&Order{})
+func migrate(db *gorm.DB) {
+	db.AutoMigrate(&Order{}, &Stop{}, &Leg{}, &Release{})
+
+	// orders created before the timestamps existed get the migration time
+	now := time.Now()
+	db.Model(&Order{}).Where("created_at IS NULL").UpdateColumns(map[string]interface{}{"created_at": now, "updated_at": now})
 }
@@ -3,10 +3,42 @@ package models
 import (
 	"github.com/jinzhu/gorm"
 	mocket "github.com/selvatico/go-mocket"
+	"reflect"
 )
 
-// change the gorm client to use the mock db for testing
-func InitMockModel() {
+// create a gorm client on the mock db for testing
+func NewMockDB() *gorm.DB {
 	mocket.Catcher.Register()
-	db, _ = gorm.Open(mocket.DriverName, "connection_string")
+	db, _ := gorm.Open(mocket.DriverName, "connection_string")
+	return db
+}
+
+// turn the orders into rows keyed by column name for the database mock
+func MockOrderRows(orders ...Order) []map[string]interface{} {
+	db := NewMockDB()
+	rows := make([]map[string]interface{}, 0, len(orders))
+
+	for i := range orders {
+		row := make(map[string]interface{})
+		for _, f := range db.NewScope(&orders[i]).Fields() {
+			// the stops and legs come from their own tables
+			if f.IsIgnored || f.Relationship != nil {
+				continue
+			}
+
+			// the mock driver can only hand back plain values
+			v := f.Field
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					row[f.DBName] = nil
+					continue
+				}
+				v = v.Elem()
+			}
+			row[f.DBName] = v.Interface()
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
 }
@@ -1,73 +1,440 @@
 package models
 
 import (
-	"github.com/jinzhu/gorm"
+	"context"
+	"fmt"
+	"math"
 	"order-service/pkgs/e"
 	"order-service/services/distance"
+	"order-service/services/geocoding"
+	"strconv"
+	"strings"
+	"time"
 )
 
-const (
-	StatusUnassigned = "UNASSIGNED"
-	StatusTaken      = "TAKEN"
-	StatusSuccess    = "SUCCESS"
-)
+// struct for a coordinate, nil for orders created before it was stored
+// Note: the address is the one formatted by the geocoder, empty when the coordinate was given
+type Location struct {
+	Lat     *float64 `json:"lat"`
+	Lng     *float64 `json:"lng"`
+	Address string   `json:"address"`
+}
+
+// struct for a point of the order as requested, either a [lat, lng] pair or a free-form address
+type Endpoint struct {
+	Coordinate []string
+	Address    string
+}
+
+// error of an address of the order which the geocoder can not find
+type AddressNotFoundError struct {
+	// endpoint of the order like origin
+	Endpoint string
+	Address  string
+}
+
+func (a *AddressNotFoundError) Error() string {
+	return fmt.Sprintf("%s address %q could not be found", a.Endpoint, a.Address)
+}
 
+// Note: distance provider, duration and mode are empty for the orders created before they were stored,
+// the duration is also empty when the provider does not estimate it
 type Order struct {
-	ID       int64  `gorm:"PRIMARY_KEY;AUTO_INCREMENT" json:"id"`
-	Distance int    `json:"distance"`
-	Status   string `json:"status"`
+	ID               int64      `gorm:"PRIMARY_KEY;AUTO_INCREMENT" json:"id"`
+	Origin           Location   `gorm:"embedded;embedded_prefix:origin_" json:"origin"`
+	Destination      Location   `gorm:"embedded;embedded_prefix:destination_" json:"destination"`
+	Stops            []Stop     `json:"stops,omitempty"`
+	Distance         int        `gorm:"index:idx_orders_distance" json:"distance"`
+	DistanceProvider string     `json:"distance_provider"`
+	DurationSeconds  *int       `json:"duration_seconds"`
+	Legs             []Leg      `json:"legs,omitempty"`
+	Mode             string     `json:"mode"`
+	Status           string     `gorm:"index:idx_orders_status" json:"status"`
+	AssigneeID       string     `gorm:"index:idx_orders_assignee_id" json:"assignee_id"`
+	Releases         []Release  `json:"releases,omitempty"`
+	CreatedAt        time.Time  `gorm:"index:idx_orders_created_at" json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	TakenAt          *time.Time `json:"taken_at"`
+	PickedUpAt       *time.Time `json:"picked_up_at"`
+	DeliveredAt      *time.Time `json:"delivered_at"`
+	CancelledAt      *time.Time `json:"cancelled_at"`
+	CancelledBy      string     `json:"cancelled_by"`
+	CancelReason     string     `json:"cancel_reason"`
+	CancelNote       string     `json:"cancel_note"`
+	FailedAt         *time.Time `json:"failed_at"`
+}
+
+// struct for a stop of the order between the origin and the destination, in the order of the sequence
+type Stop struct {
+	ID       int64    `gorm:"PRIMARY_KEY;AUTO_INCREMENT" json:"-"`
+	OrderID  int64    `gorm:"index" json:"-"`
+	Sequence int      `json:"sequence"`
+	Location Location `gorm:"embedded" json:"location"`
+}
+
+// table of the stops
+func (Stop) TableName() string {
+	return "order_stops"
+}
+
+// struct for the route between two consecutive points of the order, from the origin over the stops to the destination
+// Note: the orders created before the legs were stored have none
+type Leg struct {
+	ID               int64  `gorm:"PRIMARY_KEY;AUTO_INCREMENT" json:"-"`
+	OrderID          int64  `gorm:"index" json:"-"`
+	Sequence         int    `json:"sequence"`
+	Distance         int    `json:"distance"`
+	DistanceProvider string `json:"distance_provider"`
+	DurationSeconds  *int   `json:"duration_seconds"`
+}
+
+// table of the legs
+func (Leg) TableName() string {
+	return "order_legs"
+}
+
+// struct for a courier giving a taken order back to the pool
+type Release struct {
+	ID        int64     `gorm:"PRIMARY_KEY;AUTO_INCREMENT" json:"-"`
+	OrderID   int64     `gorm:"index" json:"-"`
+	CourierID string    `json:"courier_id"`
+	CreatedAt time.Time `json:"released_at"`
+}
+
+// table of the releases
+func (Release) TableName() string {
+	return "order_releases"
+}
+
+// function to copy the order together with its stops, legs and releases
+func (o Order) clone() *Order {
+	o.Stops = append([]Stop(nil), o.Stops...)
+	o.Legs = append([]Leg(nil), o.Legs...)
+	o.Releases = append([]Release(nil), o.Releases...)
+	return &o
+}
+
+// function to check if the courier released the order after the time
+func (o Order) releasedSince(courierID string, since time.Time) bool {
+	for _, r := range o.Releases {
+		if r.CourierID == courierID && r.CreatedAt.After(since) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// function to create a location from the [lat, lng] pair of the request
+// Note: anything other than a pair of numbers is left empty since it is not a coordinate
+func newLocation(c []string) Location {
+	var l Location
+	if len(c) != 2 {
+		return l
+	}
+
+	lat, err := strconv.ParseFloat(c[0], 64)
+	if err != nil || math.IsNaN(lat) || math.IsInf(lat, 0) {
+		return l
+	}
+
+	lng, err := strconv.ParseFloat(c[1], 64)
+	if err != nil || math.IsNaN(lng) || math.IsInf(lng, 0) {
+		return l
+	}
+
+	l.Lat = &lat
+	l.Lng = &lng
+
+	return l
+}
+
+// function to round the travel time to seconds, nil when it is unknown
+func durationSeconds(d time.Duration) *int {
+	if d <= 0 {
+		return nil
+	}
+
+	s := int(math.Round(d.Seconds()))
+	return &s
+}
+
+// service for the orders on top of its own repository, distance calculator and geocoder
+type OrderService struct {
+	repo     OrderRepository
+	calc     distance.Calculator
+	geocoder geocoding.Geocoder
+	// time a courier has to wait to take again the order they released, 0 is none
+	releaseCooldown time.Duration
+	// time the distance of every leg together may take with the retries and fallbacks, 0 is no limit
+	lookupTimeout time.Duration
+}
+
+// create a new order service, each instance can use different backends
+// Note: the geocoder can be nil, the orders with addresses are rejected then
+func NewOrderService(repo OrderRepository, calc distance.Calculator, geocoder geocoding.Geocoder) *OrderService {
+	return &OrderService{repo: repo, calc: calc, geocoder: geocoder}
+}
+
+// set the time a courier has to wait to take again the order they released
+func (s *OrderService) WithReleaseCooldown(d time.Duration) *OrderService {
+	s.releaseCooldown = d
+	return s
 }
 
-// function to create an order base on the src to des
-func CreateOrder(src []string, des []string) (*Order, error) {
-	calc := distance.GetCalculator()
+// set the time the distance of every leg of an order together may take
+func (s *OrderService) WithLookupTimeout(d time.Duration) *OrderService {
+	s.lookupTimeout = d
+	return s
+}
+
+// function to create an order base on the src to des, passing the stops in between
+func (s *OrderService) CreateOrder(ctx context.Context, src Endpoint, des Endpoint, opts distance.Options, stops ...Endpoint) (*Order, error) {
+	// resolve the addresses before the distance is calculated between the coordinates
+	origin, srcCoordinate, err := s.resolve(ctx, "origin", src)
+	if err != nil {
+		return nil, err
+	}
+
+	coordinates := [][]string{srcCoordinate}
+	orderStops := make([]Stop, 0, len(stops))
+	for i, stop := range stops {
+		l, coordinate, err := s.resolve(ctx, fmt.Sprintf("stops[%d]", i), stop)
+		if err != nil {
+			return nil, err
+		}
+		orderStops = append(orderStops, Stop{Sequence: i, Location: l})
+		coordinates = append(coordinates, coordinate)
+	}
+
+	destination, desCoordinate, err := s.resolve(ctx, "destination", des)
+	if err != nil {
+		return nil, err
+	}
+	coordinates = append(coordinates, desCoordinate)
 
-	// calculate the distance
-	d, err := calc.Calculate(src, des)
+	// calculate the distance of every leg
+	legs, err := s.route(ctx, coordinates, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// create the order in the db
-	o := Order{Distance: d, Status: StatusUnassigned}
-	if err := db.Create(&o).Error; err != nil {
+	// create the order in the repository
+	o := Order{
+		Origin:      origin,
+		Destination: destination,
+		Stops:       orderStops,
+		Legs:        legs,
+		Mode:        opts.TravelMode(),
+		Status:      StatusUnassigned,
+	}
+	o.Distance, o.DistanceProvider, o.DurationSeconds = total(legs)
+	if err := s.repo.Create(ctx, &o); err != nil {
 		return nil, err
 	}
 
 	return &o, nil
 }
 
-// function to take order based on the id provided
-func TakeOrder(id int64) error {
-	var o Order
+// function to calculate the legs between every two consecutive coordinates
+// Note: the retries and fallbacks of every leg share a single deadline, e.ErrTimeout when it is hit
+func (s *OrderService) route(ctx context.Context, coordinates [][]string, opts distance.Options) ([]Leg, error) {
+	parent := ctx
+	if s.lookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.lookupTimeout)
+		defer cancel()
+	}
+
+	legs := make([]Leg, 0, len(coordinates)-1)
+	for i := 1; i < len(coordinates); i++ {
+		res, err := s.calc.Calculate(ctx, coordinates[i-1], coordinates[i], opts)
+		if err != nil {
+			// the deadline of the lookup is hit rather than the one of the caller
+			if ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+				return nil, e.ErrTimeout
+			}
+			return nil, err
+		}
+
+		legs = append(legs, Leg{
+			Sequence:         i - 1,
+			Distance:         res.Distance,
+			DistanceProvider: res.Provider,
+			DurationSeconds:  durationSeconds(res.Duration),
+		})
+	}
+
+	return legs, nil
+}
+
+// function to sum up the legs, the duration is only known when it is known for every leg
+// Note: the providers are listed in the order they are used when the fallback answered some of the legs
+func total(legs []Leg) (int, string, *int) {
+	var (
+		d         int
+		providers []string
+		seconds   int
+		known     = true
+	)
+	for _, leg := range legs {
+		d += leg.Distance
+		if !contains(providers, leg.DistanceProvider) {
+			providers = append(providers, leg.DistanceProvider)
+		}
+		if leg.DurationSeconds == nil {
+			known = false
+		} else {
+			seconds += *leg.DurationSeconds
+		}
+	}
+
+	if !known {
+		return d, strings.Join(providers, ","), nil
+	}
+
+	return d, strings.Join(providers, ","), &seconds
+}
+
+// check if the value is in the list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// function to resolve the endpoint to its location and the coordinate for the distance calculator
+func (s *OrderService) resolve(ctx context.Context, name string, p Endpoint) (Location, []string, error) {
+	if p.Address == "" {
+		return newLocation(p.Coordinate), p.Coordinate, nil
+	}
+
+	if s.geocoder == nil {
+		return Location{}, nil, e.ErrGeocodingDisabled
+	}
+
+	place, err := s.geocoder.Geocode(ctx, p.Address)
+	if err != nil {
+		if err == e.ErrAddressNotFound {
+			return Location{}, nil, &AddressNotFoundError{Endpoint: name, Address: p.Address}
+		}
+		return Location{}, nil, err
+	}
+
+	coordinate := []string{
+		strconv.FormatFloat(place.Lat, 'f', -1, 64),
+		strconv.FormatFloat(place.Lng, 'f', -1, 64),
+	}
+	l := Location{Lat: &place.Lat, Lng: &place.Lng, Address: place.FormattedAddress}
+
+	return l, coordinate, nil
+}
+
+// function to retrieve a single order based on the id provided
+func (s *OrderService) GetOrder(ctx context.Context, id int64) (*Order, error) {
+	return s.repo.Get(ctx, id)
+}
 
-	// check if there is a order based on the id
-	err := db.Where("id = ?", id).First(&o).Error
+// function to move the order to the status if the transition table allows it from its current status,
+// the courier becomes the assignee of a taken order and only the assignee moves it on from there,
+// e.ErrOrderNotAssignee for any other courier, moving it back to unassigned releases it the same as ReleaseOrder
+func (s *OrderService) UpdateStatus(ctx context.Context, id int64, status string, courierID string) error {
+	o, err := s.repo.Get(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	// check if it is taken
-	if o.Status == StatusTaken {
-		return e.ErrOrderAlreadyTaken
+	if !CanTransition(o.Status, status) {
+		return &e.TransitionError{Current: o.Status, Requested: status}
+	}
+
+	// taking keeps its own error for the courier who lost the race
+	if status == StatusTaken {
+		if s.releaseCooldown > 0 && o.releasedSince(courierID, time.Now().Add(-s.releaseCooldown)) {
+			return e.ErrOrderReleaseCooldown
+		}
+		return s.repo.Take(ctx, id, courierID)
 	}
 
-	// found and modify status
-	if err := db.Model(&o).Update("status", StatusTaken).Error; err != nil {
+	// giving the order back clears the assignee and records the release for the cooldown
+	if status == StatusUnassigned {
+		return s.repo.Release(ctx, id, courierID)
+	}
+
+	// the repository checks the assignee in the same update so the order can not change hands in between
+	return s.repo.UpdateStatus(ctx, id, o.Status, status, courierID)
+}
+
+// function to give the taken order back to the pool, only the courier who took it can release it
+func (s *OrderService) ReleaseOrder(ctx context.Context, id int64, courierID string) error {
+	return s.repo.Release(ctx, id, courierID)
+}
+
+// function to cancel the order if it is still unassigned or taken
+func (s *OrderService) CancelOrder(ctx context.Context, id int64, c Cancellation) error {
+	o, err := s.repo.Get(ctx, id)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if !CanTransition(o.Status, StatusCancelled) {
+		return cancelErr(o.Status)
+	}
+
+	err = s.repo.Cancel(ctx, id, o.Status, c)
+	if terr, ok := err.(*e.TransitionError); ok {
+		// someone else has moved the order in the meantime
+		return cancelErr(terr.Current)
+	}
+
+	return err
 }
 
-// function to retrieve paged orders
-func GetOrders(page int, limit int) ([]*Order, error) {
-	var orders []*Order
+// function to explain why an order in the status can not be cancelled
+func cancelErr(status string) error {
+	switch status {
+	case StatusCancelled:
+		return e.ErrOrderAlreadyCancelled
+	case StatusDelivered:
+		return e.ErrOrderAlreadyDelivered
+	default:
+		return &e.TransitionError{Current: status, Requested: StatusCancelled}
+	}
+}
 
-	err := db.Offset(page * limit).Limit(limit).Find(&orders).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
-		return nil, err
+// function to retrieve paged orders matching the filter
+func (s *OrderService) GetOrders(ctx context.Context, filter OrderFilter, page int, limit int) ([]*Order, error) {
+	return s.repo.List(ctx, filter, page, limit)
+}
+
+// function to retrieve the orders matching the filter after its cursor, with the cursor of the next orders
+// or nil when there are no more
+// Note: one more order than the limit is read to know if there is a next page
+func (s *OrderService) GetOrdersAfter(ctx context.Context, filter OrderFilter, limit int) ([]*Order, *Cursor, error) {
+	if limit <= 0 {
+		return make([]*Order, 0), nil, nil
+	}
+
+	orders, err := s.repo.List(ctx, filter, 0, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(orders) <= limit {
+		return orders, nil, nil
 	}
 
-	return orders, nil
+	orders = orders[:limit]
+	next := CursorOf(orders[limit-1])
+
+	return orders, &next, nil
+}
+
+// function to count the orders for every status
+func (s *OrderService) CountOrdersByStatus(ctx context.Context) (map[string]int64, error) {
+	return s.repo.CountByStatus(ctx)
 }
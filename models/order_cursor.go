@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"order-service/pkgs/e"
+	"time"
+)
+
+// position of an order in the sorts by creation time, the id breaks the ties of the same time
+// Note: the clients only see it encoded so its content can change without breaking them
+type Cursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        int64     `json:"i"`
+}
+
+// the cursor right at the order
+func CursorOf(o *Order) Cursor {
+	return Cursor{CreatedAt: o.CreatedAt, ID: o.ID}
+}
+
+// encode the cursor to the opaque string handed to the clients
+func (c Cursor) Encode() string {
+	// a struct of a time and an int always marshals
+	body, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+// decode the cursor of a client, e.ErrCursorInvalid when it was not made by Encode
+func DecodeCursor(value string) (Cursor, error) {
+	var c Cursor
+
+	body, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, e.ErrCursorInvalid
+	}
+
+	if err := json.Unmarshal(body, &c); err != nil || c.ID <= 0 || c.CreatedAt.IsZero() {
+		return Cursor{}, e.ErrCursorInvalid
+	}
+
+	return c, nil
+}
+
+// the order standing at the cursor to compare with, only its creation time and id are known
+func (c Cursor) order() *Order {
+	return &Order{ID: c.ID, CreatedAt: c.CreatedAt}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"order-service/pkgs/e"
+	"testing"
+	"time"
+)
+
+// test for the encoded cursor decode back to the same position
+func TestCursor_Encode(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	o := &Order{ID: 42, CreatedAt: time.Date(2019, 7, 1, 10, 0, 0, 123456789, time.UTC)}
+	encoded := CursorOf(o).Encode()
+
+	a.NotContains(encoded, "=", "cursor should be safe in a query string without escaping")
+
+	c, err := DecodeCursor(encoded)
+	a.Nil(err, "cursor should be decoded without err")
+	a.Equal(o.ID, c.ID, "cursor should keep the id")
+	a.True(o.CreatedAt.Equal(c.CreatedAt), "cursor should keep the creation time to the nanosecond")
+}
+
+// test for error from decode a cursor which was not encoded by the service
+func TestDecodeCursor_Invalid(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	for _, value := range []string{
+		"not a cursor",
+		base64.RawURLEncoding.EncodeToString([]byte("42")),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"c":"2019-07-01T10:00:00Z"}`)),
+		base64.RawURLEncoding.EncodeToString([]byte(`{"i":42}`)),
+	} {
+		_, err := DecodeCursor(value)
+		a.Equal(e.ErrCursorInvalid, err, "cursor %q should be invalid", value)
+	}
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// sorts of the listed orders, a leading - sorts descending and the id breaks the ties
+const (
+	SortID            = "id"
+	SortDistance      = "distance"
+	SortDistanceDesc  = "-distance"
+	SortCreatedAt     = "created_at"
+	SortCreatedAtDesc = "-created_at"
+)
+
+// every sort of the listed orders
+var Sorts = []string{SortID, SortDistance, SortDistanceDesc, SortCreatedAt, SortCreatedAtDesc}
+
+// filter and sort of the listed orders, the zero value matches every order sorted by id
+type OrderFilter struct {
+	// status of the order
+	Status string
+	// courier who took the order
+	AssigneeID string
+	// distance range in meters, both ends included
+	MinDistance *int
+	MaxDistance *int
+	// creation time range, the after is included and the before is not
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// one of the Sorts, by id when empty
+	Sort string
+	// only the orders after the cursor in the sort, which has to be by creation time
+	After *Cursor
+}
+
+// check if the order matches the filter
+func (f OrderFilter) Match(o *Order) bool {
+	switch {
+	case f.Status != "" && o.Status != f.Status:
+		return false
+	case f.AssigneeID != "" && o.AssigneeID != f.AssigneeID:
+		return false
+	case f.MinDistance != nil && o.Distance < *f.MinDistance:
+		return false
+	case f.MaxDistance != nil && o.Distance > *f.MaxDistance:
+		return false
+	case !f.CreatedAfter.IsZero() && o.CreatedAt.Before(f.CreatedAfter):
+		return false
+	case !f.CreatedBefore.IsZero() && !o.CreatedAt.Before(f.CreatedBefore):
+		return false
+	case f.After != nil && !f.Less(f.After.order(), o):
+		return false
+	}
+
+	return true
+}
+
+// check if the order a comes before the order b in the sort of the filter
+func (f OrderFilter) Less(a *Order, b *Order) bool {
+	switch f.Sort {
+	case SortDistance:
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+	case SortDistanceDesc:
+		if a.Distance != b.Distance {
+			return a.Distance > b.Distance
+		}
+		return a.ID > b.ID
+	case SortCreatedAt:
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	case SortCreatedAtDesc:
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	}
+
+	return a.ID < b.ID
+}
+
+// storage of the orders, every implementation has to pass the shared conformance tests
+// Note: every call gives up with the error of the context once it is done
+type OrderRepository interface {
+	// store a new order and fill in its id and timestamps
+	Create(ctx context.Context, o *Order) error
+
+	// get a single order, e.ErrOrderNotExist when it is missing
+	Get(ctx context.Context, id int64) (*Order, error)
+
+	// get a page of the orders matching the filter, empty when the page is out of range
+	List(ctx context.Context, filter OrderFilter, page int, limit int) ([]*Order, error)
+
+	// move an unassigned order to taken by the courier atomically so only one caller can win,
+	// e.ErrOrderNotExist when it is missing and e.ErrOrderAlreadyTaken when lost
+	Take(ctx context.Context, id int64, courierID string) error
+
+	// move a taken order back to unassigned atomically and record the release, only for the courier who took it,
+	// e.ErrOrderNotExist when it is missing, *e.TransitionError when it is not taken
+	// and e.ErrOrderNotAssignee when another courier took it
+	Release(ctx context.Context, id int64, courierID string) error
+
+	// move the order from one status to another atomically so only one caller can win, only for the courier
+	// who holds the order or any courier when it has no assignee,
+	// e.ErrOrderNotExist when it is missing, *e.TransitionError when it is no longer in the from status
+	// and e.ErrOrderNotAssignee when another courier holds it
+	UpdateStatus(ctx context.Context, id int64, from string, to string, courierID string) error
+
+	// cancel the order the same as moving it from the status to cancelled, recording who cancelled it and why
+	Cancel(ctx context.Context, id int64, from string, c Cancellation) error
+
+	// count the orders for every status which has any
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+}
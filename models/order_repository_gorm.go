@@ -0,0 +1,285 @@
+package models
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"order-service/pkgs/e"
+	"time"
+)
+
+type gormOrderRepository struct {
+	db *gorm.DB
+}
+
+// create the order repository on top of the gorm client
+// Note: gorm v1 can not cancel a running query, so the context is checked before every query
+func NewGormOrderRepository(db *gorm.DB) OrderRepository {
+	return &gormOrderRepository{db: db}
+}
+
+// store the order in the db, the stops and legs are stored with it
+func (r *gormOrderRepository) Create(ctx context.Context, o *Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return r.db.Create(o).Error
+}
+
+// retrieve a single order based on the id provided
+func (r *gormOrderRepository) Get(ctx context.Context, id int64) (*Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var o Order
+
+	err := withChildren(r.db).Where("id = ?", id).First(&o).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, e.ErrOrderNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	dropEmptyChildren(&o)
+
+	return &o, nil
+}
+
+// order by clauses of the sorts, the values of the filter never reach the sql but as arguments
+var sortClauses = map[string]string{
+	SortID:            "id ASC",
+	SortDistance:      "distance ASC, id ASC",
+	SortDistanceDesc:  "distance DESC, id DESC",
+	SortCreatedAt:     "created_at ASC, id ASC",
+	SortCreatedAtDesc: "created_at DESC, id DESC",
+}
+
+// function to add the conditions and the sort of the filter to the query
+func filtered(db *gorm.DB, filter OrderFilter) *gorm.DB {
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.AssigneeID != "" {
+		db = db.Where("assignee_id = ?", filter.AssigneeID)
+	}
+	if filter.MinDistance != nil {
+		db = db.Where("distance >= ?", *filter.MinDistance)
+	}
+	if filter.MaxDistance != nil {
+		db = db.Where("distance <= ?", *filter.MaxDistance)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		db = db.Where("created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		db = db.Where("created_at < ?", filter.CreatedBefore)
+	}
+
+	// keyset of the sorts by creation time, the index of the creation time also holds the id
+	if filter.After != nil {
+		at, id := filter.After.CreatedAt, filter.After.ID
+		if filter.Sort == SortCreatedAtDesc {
+			db = db.Where("created_at < ? OR (created_at = ? AND id < ?)", at, at, id)
+		} else {
+			db = db.Where("created_at > ? OR (created_at = ? AND id > ?)", at, at, id)
+		}
+	}
+
+	// an unknown sort never makes it to the sql
+	clause, ok := sortClauses[filter.Sort]
+	if !ok {
+		clause = sortClauses[SortID]
+	}
+
+	return db.Order(clause)
+}
+
+// retrieve paged orders matching the filter in its sort
+func (r *gormOrderRepository) List(ctx context.Context, filter OrderFilter, page int, limit int) ([]*Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var orders []*Order
+
+	err := filtered(withChildren(r.db), filter).Offset(page * limit).Limit(limit).Find(&orders).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	for _, o := range orders {
+		dropEmptyChildren(o)
+	}
+
+	return orders, nil
+}
+
+// load the stops and legs of the orders in their sequence and the releases in the order they happened
+func withChildren(db *gorm.DB) *gorm.DB {
+	bySequence := func(db *gorm.DB) *gorm.DB { return db.Order("sequence") }
+	byID := func(db *gorm.DB) *gorm.DB { return db.Order("id") }
+	return db.Preload("Stops", bySequence).Preload("Legs", bySequence).Preload("Releases", byID)
+}
+
+// leave the stops, legs and releases nil when there is none, the same as the other repositories
+func dropEmptyChildren(o *Order) {
+	if len(o.Stops) == 0 {
+		o.Stops = nil
+	}
+	if len(o.Legs) == 0 {
+		o.Legs = nil
+	}
+	if len(o.Releases) == 0 {
+		o.Releases = nil
+	}
+}
+
+// take order based on the id provided for the courier
+func (r *gormOrderRepository) Take(ctx context.Context, id int64, courierID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// take the order in a single conditional update so only one caller can win
+	res := r.db.Model(&Order{}).
+		Where("id = ? AND status = ?", id, StatusUnassigned).
+		Updates(map[string]interface{}{"status": StatusTaken, "taken_at": time.Now(), "assignee_id": courierID})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 1 {
+		return nil
+	}
+
+	// nothing updated, check if there is a order based on the id
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+
+	// the order exists so someone else has taken it
+	return e.ErrOrderAlreadyTaken
+}
+
+// give the order back to the pool and record the release in the same transaction
+func (r *gormOrderRepository) Release(ctx context.Context, id int64, courierID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx := r.db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// release only when the courier still holds the order so only one caller can win
+	res := tx.Model(&Order{}).
+		Where("id = ? AND status = ? AND assignee_id = ?", id, StatusTaken, courierID).
+		Updates(map[string]interface{}{"status": StatusUnassigned, "taken_at": nil, "assignee_id": ""})
+	if res.Error != nil {
+		tx.Rollback()
+		return res.Error
+	}
+
+	if res.RowsAffected == 1 {
+		if err := tx.Create(&Release{OrderID: id, CourierID: courierID}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit().Error
+	}
+	tx.Rollback()
+
+	// nothing updated, check why the order can not be released
+	o, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if o.Status != StatusTaken {
+		return &e.TransitionError{Current: o.Status, Requested: StatusUnassigned}
+	}
+
+	return e.ErrOrderNotAssignee
+}
+
+// move the order to the status for the courier who holds it
+func (r *gormOrderRepository) UpdateStatus(ctx context.Context, id int64, from string, to string, courierID string) error {
+	return r.updateStatus(ctx, id, from, to, courierID, map[string]interface{}{})
+}
+
+// cancel the order and record who cancelled it and why
+func (r *gormOrderRepository) Cancel(ctx context.Context, id int64, from string, c Cancellation) error {
+	updates := map[string]interface{}{"cancelled_by": c.By, "cancel_reason": c.Reason, "cancel_note": c.Note}
+	return r.updateStatus(ctx, id, from, StatusCancelled, "", updates)
+}
+
+// move the order to the status with the other updates and record the time it reached it,
+// any courier can move it when the courier id is empty
+func (r *gormOrderRepository) updateStatus(ctx context.Context, id int64, from string, to string, courierID string, updates map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	updates["status"] = to
+	if column, ok := statusTimeColumns[to]; ok {
+		updates[column] = time.Now()
+	}
+
+	// update only when the order is still in the from status so only one caller can win
+	// Note: the orders taken before the assignee was stored have none, any courier can move them on
+	db := r.db.Model(&Order{}).Where("id = ? AND status = ?", id, from)
+	if courierID != "" {
+		db = db.Where("assignee_id = ? OR assignee_id = ''", courierID)
+	}
+
+	res := db.Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 1 {
+		return nil
+	}
+
+	// nothing updated, check if there is a order based on the id
+	o, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// someone else has moved the order in the meantime
+	if o.Status != from {
+		return &e.TransitionError{Current: o.Status, Requested: to}
+	}
+
+	// or another courier holds it now
+	return e.ErrOrderNotAssignee
+}
+
+// count the orders grouped by status
+func (r *gormOrderRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Model(&Order{}).Select("status, count(*)").Group("status").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var (
+			status string
+			count  int64
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
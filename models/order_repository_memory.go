@@ -0,0 +1,203 @@
+package models
+
+import (
+	"context"
+	"order-service/pkgs/e"
+	"sort"
+	"sync"
+	"time"
+)
+
+// thread-safe order repository kept in the process memory
+type memoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders map[int64]*Order
+	lastID int64
+}
+
+// create an empty in-memory order repository
+func NewMemoryOrderRepository() OrderRepository {
+	return &memoryOrderRepository{orders: make(map[int64]*Order)}
+}
+
+// store a copy of the order with the next id
+func (r *memoryOrderRepository) Create(ctx context.Context, o *Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.lastID++
+	o.ID = r.lastID
+	o.CreatedAt = now
+	o.UpdatedAt = now
+
+	r.orders[o.ID] = o.clone()
+
+	return nil
+}
+
+// retrieve a copy of a single order based on the id provided
+func (r *memoryOrderRepository) Get(ctx context.Context, id int64) (*Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, ok := r.orders[id]
+	if !ok {
+		return nil, e.ErrOrderNotExist
+	}
+
+	return o.clone(), nil
+}
+
+// retrieve copies of the paged orders matching the filter in its sort
+func (r *memoryOrderRepository) List(ctx context.Context, filter OrderFilter, page int, limit int) ([]*Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matched := make([]*Order, 0, len(r.orders))
+	for _, o := range r.orders {
+		if filter.Match(o) {
+			matched = append(matched, o)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return filter.Less(matched[i], matched[j]) })
+
+	orders := make([]*Order, 0)
+	if page < 0 || limit < 0 {
+		return orders, nil
+	}
+
+	for i := page * limit; i < len(matched) && len(orders) < limit; i++ {
+		orders = append(orders, matched[i].clone())
+	}
+
+	return orders, nil
+}
+
+// take order based on the id provided, the lock makes the check and set atomic
+func (r *memoryOrderRepository) Take(ctx context.Context, id int64, courierID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, ok := r.orders[id]
+	if !ok {
+		return e.ErrOrderNotExist
+	}
+
+	if o.Status != StatusUnassigned {
+		return e.ErrOrderAlreadyTaken
+	}
+
+	o.setStatus(StatusTaken, time.Now())
+	o.AssigneeID = courierID
+
+	return nil
+}
+
+// give the order back to the pool and record the release, the lock makes the check and set atomic
+func (r *memoryOrderRepository) Release(ctx context.Context, id int64, courierID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, ok := r.orders[id]
+	if !ok {
+		return e.ErrOrderNotExist
+	}
+
+	if o.Status != StatusTaken {
+		return &e.TransitionError{Current: o.Status, Requested: StatusUnassigned}
+	}
+
+	if o.AssigneeID != courierID {
+		return e.ErrOrderNotAssignee
+	}
+
+	now := time.Now()
+	o.setStatus(StatusUnassigned, now)
+	o.AssigneeID = ""
+	o.TakenAt = nil
+	o.Releases = append(o.Releases, Release{OrderID: id, CourierID: courierID, CreatedAt: now})
+
+	return nil
+}
+
+// move the order to the status for the courier who holds it
+func (r *memoryOrderRepository) UpdateStatus(ctx context.Context, id int64, from string, to string, courierID string) error {
+	return r.updateStatus(ctx, id, from, to, courierID, func(o *Order) {})
+}
+
+// cancel the order and record who cancelled it and why
+func (r *memoryOrderRepository) Cancel(ctx context.Context, id int64, from string, c Cancellation) error {
+	return r.updateStatus(ctx, id, from, StatusCancelled, "", func(o *Order) {
+		o.CancelledBy = c.By
+		o.CancelReason = c.Reason
+		o.CancelNote = c.Note
+	})
+}
+
+// move the order to the status and apply the other changes, the lock makes the check and set atomic,
+// any courier can move it when the courier id is empty
+func (r *memoryOrderRepository) updateStatus(ctx context.Context, id int64, from string, to string, courierID string, apply func(o *Order)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, ok := r.orders[id]
+	if !ok {
+		return e.ErrOrderNotExist
+	}
+
+	if o.Status != from {
+		return &e.TransitionError{Current: o.Status, Requested: to}
+	}
+
+	// Note: the orders taken before the assignee was stored have none, any courier can move them on
+	if courierID != "" && o.AssigneeID != "" && o.AssigneeID != courierID {
+		return e.ErrOrderNotAssignee
+	}
+
+	o.setStatus(to, time.Now())
+	apply(o)
+
+	return nil
+}
+
+// count the orders grouped by status
+func (r *memoryOrderRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, o := range r.orders {
+		counts[o.Status]++
+	}
+
+	return counts, nil
+}
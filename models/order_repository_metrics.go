@@ -0,0 +1,105 @@
+package models
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"order-service/pkgs/e"
+	"time"
+)
+
+var (
+	repositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "order_service",
+		Name:      "repository_duration_seconds",
+		Help:      "Duration of the order repository calls by operation.",
+	}, []string{"operation"})
+
+	repositoryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "order_service",
+		Name:      "repository_errors_total",
+		Help:      "Failed order repository calls by operation, not found and lost takes excluded.",
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(repositoryDuration, repositoryErrors)
+}
+
+// decorator recording the latency and errors of every repository call
+type instrumentedOrderRepository struct {
+	repo OrderRepository
+}
+
+// wrap the repository to record metrics labelled by the operation
+func NewInstrumentedOrderRepository(repo OrderRepository) OrderRepository {
+	return &instrumentedOrderRepository{repo: repo}
+}
+
+// record the duration and the error of a single call
+func observeRepository(operation string, start time.Time, err error) {
+	repositoryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+	// expected outcomes are not errors of the storage
+	if _, ok := err.(*e.TransitionError); ok {
+		return
+	}
+	if err != nil && err != e.ErrOrderNotExist && err != e.ErrOrderAlreadyTaken && err != e.ErrOrderNotAssignee {
+		repositoryErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (r *instrumentedOrderRepository) Create(ctx context.Context, o *Order) error {
+	start := time.Now()
+	err := r.repo.Create(ctx, o)
+	observeRepository("create", start, err)
+	return err
+}
+
+func (r *instrumentedOrderRepository) Get(ctx context.Context, id int64) (*Order, error) {
+	start := time.Now()
+	o, err := r.repo.Get(ctx, id)
+	observeRepository("get", start, err)
+	return o, err
+}
+
+func (r *instrumentedOrderRepository) List(ctx context.Context, filter OrderFilter, page int, limit int) ([]*Order, error) {
+	start := time.Now()
+	orders, err := r.repo.List(ctx, filter, page, limit)
+	observeRepository("list", start, err)
+	return orders, err
+}
+
+func (r *instrumentedOrderRepository) Take(ctx context.Context, id int64, courierID string) error {
+	start := time.Now()
+	err := r.repo.Take(ctx, id, courierID)
+	observeRepository("take", start, err)
+	return err
+}
+
+func (r *instrumentedOrderRepository) Release(ctx context.Context, id int64, courierID string) error {
+	start := time.Now()
+	err := r.repo.Release(ctx, id, courierID)
+	observeRepository("release", start, err)
+	return err
+}
+
+func (r *instrumentedOrderRepository) UpdateStatus(ctx context.Context, id int64, from string, to string, courierID string) error {
+	start := time.Now()
+	err := r.repo.UpdateStatus(ctx, id, from, to, courierID)
+	observeRepository("update_status", start, err)
+	return err
+}
+
+func (r *instrumentedOrderRepository) Cancel(ctx context.Context, id int64, from string, c Cancellation) error {
+	start := time.Now()
+	err := r.repo.Cancel(ctx, id, from, c)
+	observeRepository("cancel", start, err)
+	return err
+}
+
+func (r *instrumentedOrderRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	start := time.Now()
+	counts, err := r.repo.CountByStatus(ctx)
+	observeRepository("count_by_status", start, err)
+	return counts, err
+}
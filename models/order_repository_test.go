@@ -0,0 +1,647 @@
+package models
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"order-service/config"
+	"order-service/pkgs/e"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// courier taking the orders in the tests
+const testCourierID = "courier-1"
+
+// number of couriers trying to take the same order at once
+const concurrentTakers = 50
+
+// function to create an empty repository for each conformance test
+type repositoryFactory func() OrderRepository
+
+// test the in-memory repository against the conformance tests
+func TestMemoryOrderRepository(t *testing.T) {
+	t.Parallel()
+
+	testOrderRepository(t, NewMemoryOrderRepository)
+}
+
+// test the instrumented repository keep the semantics of the wrapped one
+func TestInstrumentedOrderRepository(t *testing.T) {
+	t.Parallel()
+
+	testOrderRepository(t, func() OrderRepository {
+		return NewInstrumentedOrderRepository(NewMemoryOrderRepository())
+	})
+}
+
+// test the gorm repository against the conformance tests on a real local database
+// Note: it needs MYSQL_HOSTNAME and friends in the env, and it wipes the tables of the orders,
+// so MYSQL_SCHEMA has to point to a schema of its own
+func TestGormOrderRepository(t *testing.T) {
+	if os.Getenv("MYSQL_HOSTNAME") == "" {
+		t.Skip("MYSQL_HOSTNAME is not set, skip the test against a real database")
+	}
+
+	config.InitConfig()
+	dbConfig := config.GetConfig().DbConfig
+	if dbConfig.GetSchemaName() == config.DefaultSchema {
+		t.Fatalf("refuse to wipe the orders of the %q schema, set MYSQL_SCHEMA to a test schema", config.DefaultSchema)
+	}
+
+	db, err := NewDB(dbConfig.GetConnectionString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testOrderRepository(t, func() OrderRepository {
+		db.Delete(&Stop{})
+		db.Delete(&Leg{})
+		db.Delete(&Release{})
+		db.Delete(&Order{})
+		return NewGormOrderRepository(db)
+	})
+}
+
+// conformance tests every order repository has to pass
+func testOrderRepository(t *testing.T, newRepo repositoryFactory) {
+	t.Run("Create", func(t *testing.T) { testRepositoryCreate(t, newRepo()) })
+	t.Run("Get", func(t *testing.T) { testRepositoryGet(t, newRepo()) })
+	t.Run("Stops", func(t *testing.T) { testRepositoryStops(t, newRepo()) })
+	t.Run("Get_Not_Exist", func(t *testing.T) { testRepositoryGetNotExist(t, newRepo()) })
+	t.Run("List", func(t *testing.T) { testRepositoryList(t, newRepo()) })
+	t.Run("List_Assignee", func(t *testing.T) { testRepositoryListAssignee(t, newRepo()) })
+	t.Run("List_Filter", func(t *testing.T) { testRepositoryListFilter(t, newRepo()) })
+	t.Run("List_Sort", func(t *testing.T) { testRepositoryListSort(t, newRepo()) })
+	t.Run("List_After", func(t *testing.T) { testRepositoryListAfter(t, newRepo()) })
+	t.Run("Take", func(t *testing.T) { testRepositoryTake(t, newRepo()) })
+	t.Run("Take_Already_Taken", func(t *testing.T) { testRepositoryTakeAlreadyTaken(t, newRepo()) })
+	t.Run("Take_Not_Exist", func(t *testing.T) { testRepositoryTakeNotExist(t, newRepo()) })
+	t.Run("Take_Concurrent", func(t *testing.T) { testRepositoryTakeConcurrent(t, newRepo()) })
+	t.Run("Release", func(t *testing.T) { testRepositoryRelease(t, newRepo()) })
+	t.Run("Release_Not_Assignee", func(t *testing.T) { testRepositoryReleaseNotAssignee(t, newRepo()) })
+	t.Run("Release_Not_Taken", func(t *testing.T) { testRepositoryReleaseNotTaken(t, newRepo()) })
+	t.Run("Update_Status", func(t *testing.T) { testRepositoryUpdateStatus(t, newRepo()) })
+	t.Run("Update_Status_Conflict", func(t *testing.T) { testRepositoryUpdateStatusConflict(t, newRepo()) })
+	t.Run("Update_Status_Not_Exist", func(t *testing.T) { testRepositoryUpdateStatusNotExist(t, newRepo()) })
+	t.Run("Update_Status_Not_Assignee", func(t *testing.T) { testRepositoryUpdateStatusNotAssignee(t, newRepo()) })
+	t.Run("Cancel", func(t *testing.T) { testRepositoryCancel(t, newRepo()) })
+	t.Run("Count_By_Status", func(t *testing.T) { testRepositoryCountByStatus(t, newRepo()) })
+	t.Run("Canceled", func(t *testing.T) { testRepositoryCanceled(t, newRepo()) })
+}
+
+// helper function to store a new unassigned order
+func createTestOrder(t *testing.T, repo OrderRepository, d int) *Order {
+	lat, lng := 36.0222811, -115.0980736
+	o := Order{Origin: Location{Lat: &lat, Lng: &lng}, Distance: d, Status: StatusUnassigned}
+
+	if err := repo.Create(context.Background(), &o); err != nil {
+		t.Fatal(err)
+	}
+
+	return &o
+}
+
+// test for create fill in the id and timestamps
+func testRepositoryCreate(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	o1 := createTestOrder(t, repo, 100)
+	o2 := createTestOrder(t, repo, 200)
+
+	a.NotZero(o1.ID, "id should be assigned")
+	a.NotEqual(o1.ID, o2.ID, "ids should be unique")
+	a.WithinDuration(time.Now(), o1.CreatedAt, 5*time.Second, "creation time should be set")
+	a.WithinDuration(time.Now(), o1.UpdatedAt, 5*time.Second, "update time should be set")
+	a.Nil(o1.TakenAt, "taken time should not be set")
+}
+
+// test for get return the stored order
+func testRepositoryGet(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+
+	o, err := repo.Get(context.Background(), created.ID)
+
+	a.Nil(err, "order should be found without err")
+	a.Equal(created.ID, o.ID, "id should match")
+	a.Equal(100, o.Distance, "distance should match")
+	a.Equal(StatusUnassigned, o.Status, "status should match")
+	a.Equal(*created.Origin.Lat, *o.Origin.Lat, "origin latitude should match")
+	a.Nil(o.Destination.Lat, "destination latitude should stay empty")
+	a.WithinDuration(created.CreatedAt, o.CreatedAt, time.Second, "creation time should match")
+
+	// changing the result should not change the stored order
+	o.Status = StatusTaken
+	again, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusUnassigned, again.Status, "stored order should not be changed")
+}
+
+// test for the stops and legs are stored with the order and come back in their sequence
+func testRepositoryStops(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	lat, lng := 36.0222811, -115.0980736
+	seconds := 60
+	o := Order{
+		Stops: []Stop{
+			{Sequence: 0, Location: Location{Lat: &lat, Lng: &lng, Address: "Henderson, NV, USA"}},
+			{Sequence: 1, Location: Location{Lat: &lng, Lng: &lat}},
+		},
+		Legs: []Leg{
+			{Sequence: 0, Distance: 100, DistanceProvider: "mock", DurationSeconds: &seconds},
+			{Sequence: 1, Distance: 200, DistanceProvider: "mock"},
+			{Sequence: 2, Distance: 300, DistanceProvider: "mock"},
+		},
+		Distance: 600,
+		Status:   StatusUnassigned,
+	}
+	if err := repo.Create(context.Background(), &o); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repo.Get(context.Background(), o.ID)
+
+	a.Nil(err, "order should be found without err")
+	if a.Len(found.Stops, 2, "stops should be stored") {
+		a.Equal("Henderson, NV, USA", found.Stops[0].Location.Address, "first stop should come first")
+		a.Equal(lng, *found.Stops[1].Location.Lat, "second stop should keep its coordinate")
+	}
+	if a.Len(found.Legs, 3, "legs should be stored") {
+		for i, leg := range found.Legs {
+			a.Equal(i, leg.Sequence, "legs should be in the sequence")
+			a.Equal((i+1)*100, leg.Distance, "leg should keep its distance")
+		}
+		a.Equal(60, *found.Legs[0].DurationSeconds, "leg should keep its duration")
+		a.Nil(found.Legs[1].DurationSeconds, "unknown duration should stay empty")
+	}
+
+	orders, err := repo.List(context.Background(), OrderFilter{}, 0, 10)
+	a.Nil(err, "orders should be listed without err")
+	if a.Len(orders, 1, "order should be listed") {
+		a.Len(orders[0].Stops, 2, "listed order should have its stops")
+		a.Len(orders[0].Legs, 3, "listed order should have its legs")
+	}
+
+	// changing the result should not change the stored order
+	found.Stops[0].Sequence = 5
+	again, _ := repo.Get(context.Background(), o.ID)
+	a.Equal(0, again.Stops[0].Sequence, "stored stops should not be changed")
+}
+
+// test for get an order which does not exist
+func testRepositoryGetNotExist(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	o, err := repo.Get(context.Background(), 12345)
+
+	a.Equal(e.ErrOrderNotExist, err, "error should be order not exist")
+	a.Nil(o, "order should not be returned")
+}
+
+// test for list return the orders page by page
+func testRepositoryList(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	for i := 0; i < 5; i++ {
+		createTestOrder(t, repo, i)
+	}
+
+	// collect every page of 2
+	seen := make(map[int64]bool)
+	for page := 0; page < 3; page++ {
+		orders, err := repo.List(context.Background(), OrderFilter{}, page, 2)
+		a.Nil(err, "orders should be listed without err")
+		for _, o := range orders {
+			a.False(seen[o.ID], "order should only be on one page")
+			seen[o.ID] = true
+		}
+	}
+	a.Equal(5, len(seen), "every order should be on a page")
+
+	// page out of range
+	orders, err := repo.List(context.Background(), OrderFilter{}, 10, 2)
+	a.Nil(err, "orders should be listed without err")
+	a.NotNil(orders, "result should not be nil")
+	a.Equal(0, len(orders), "page out of range should be empty")
+
+	// zero limit
+	orders, err = repo.List(context.Background(), OrderFilter{}, 0, 0)
+	a.Nil(err, "orders should be listed without err")
+	a.Equal(0, len(orders), "zero limit should be empty")
+}
+
+// test for list only return the orders of the assignee
+func testRepositoryListAssignee(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	mine := createTestOrder(t, repo, 0)
+	theirs := createTestOrder(t, repo, 1)
+	createTestOrder(t, repo, 2)
+
+	a.Nil(repo.Take(context.Background(), mine.ID, testCourierID), "order should be taken without err")
+	a.Nil(repo.Take(context.Background(), theirs.ID, "courier-2"), "order should be taken without err")
+
+	orders, err := repo.List(context.Background(), OrderFilter{AssigneeID: testCourierID}, 0, 10)
+	a.Nil(err, "orders should be listed without err")
+	if a.Len(orders, 1, "only the order of the courier should be listed") {
+		a.Equal(mine.ID, orders[0].ID, "order of the courier should be listed")
+		a.Equal(testCourierID, orders[0].AssigneeID, "order should keep its assignee")
+	}
+
+	// a courier without orders
+	orders, err = repo.List(context.Background(), OrderFilter{AssigneeID: "courier-3"}, 0, 10)
+	a.Nil(err, "orders should be listed without err")
+	a.Equal(0, len(orders), "courier without orders should have none")
+
+	// no filter lists every order
+	orders, err = repo.List(context.Background(), OrderFilter{}, 0, 10)
+	a.Nil(err, "orders should be listed without err")
+	a.Equal(3, len(orders), "every order should be listed")
+}
+
+// test for list only return the orders matching every condition of the filter
+func testRepositoryListFilter(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	short := createTestOrder(t, repo, 100)
+	middle := createTestOrder(t, repo, 200)
+	long := createTestOrder(t, repo, 300)
+	a.Nil(repo.Take(context.Background(), middle.ID, testCourierID), "order should be taken without err")
+
+	list := func(filter OrderFilter) []int64 {
+		orders, err := repo.List(context.Background(), filter, 0, 10)
+		a.Nil(err, "orders should be listed without err")
+
+		ids := make([]int64, 0, len(orders))
+		for _, o := range orders {
+			ids = append(ids, o.ID)
+		}
+		return ids
+	}
+
+	min, max := 150, 300
+	a.Equal([]int64{short.ID, long.ID}, list(OrderFilter{Status: StatusUnassigned}), "orders should match the status")
+	a.Equal([]int64{middle.ID, long.ID}, list(OrderFilter{MinDistance: &min}), "orders should reach the min distance")
+	a.Equal([]int64{short.ID, middle.ID, long.ID}, list(OrderFilter{MaxDistance: &max}), "max distance should be included")
+	a.Equal([]int64{long.ID}, list(OrderFilter{Status: StatusUnassigned, MinDistance: &min}), "orders should match every condition")
+
+	// the creation time range
+	created := short.CreatedAt
+	a.Len(list(OrderFilter{CreatedAfter: created.Add(-time.Minute), CreatedBefore: created.Add(time.Minute)}), 3, "orders should be in the range")
+	a.Empty(list(OrderFilter{CreatedAfter: created.Add(time.Minute)}), "orders should not be created after the range")
+	a.Empty(list(OrderFilter{CreatedBefore: created.Add(-time.Minute)}), "orders should not be created before the range")
+}
+
+// test for list return the orders in the sort of the filter across the pages
+func testRepositoryListSort(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	o1 := createTestOrder(t, repo, 300)
+	o2 := createTestOrder(t, repo, 100)
+	o3 := createTestOrder(t, repo, 200)
+	o4 := createTestOrder(t, repo, 100)
+
+	list := func(sort string) []int64 {
+		var ids []int64
+		for page := 0; page < 2; page++ {
+			orders, err := repo.List(context.Background(), OrderFilter{Sort: sort}, page, 2)
+			a.Nil(err, "orders should be listed without err")
+			for _, o := range orders {
+				ids = append(ids, o.ID)
+			}
+		}
+		return ids
+	}
+
+	a.Equal([]int64{o1.ID, o2.ID, o3.ID, o4.ID}, list(""), "orders should be sorted by id by default")
+	a.Equal([]int64{o2.ID, o4.ID, o3.ID, o1.ID}, list(SortDistance), "orders should be sorted by distance then id")
+	a.Equal([]int64{o1.ID, o3.ID, o4.ID, o2.ID}, list(SortDistanceDesc), "orders should be sorted by distance descending")
+	a.Equal([]int64{o4.ID, o3.ID, o2.ID, o1.ID}, list(SortCreatedAtDesc), "newest orders should be first")
+}
+
+// test for list continue after the cursor in both sorts by creation time, also when orders are created meanwhile
+func testRepositoryListAfter(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	var created []int64
+	for i := 0; i < 3; i++ {
+		created = append(created, createTestOrder(t, repo, i).ID)
+	}
+
+	// walk the orders 2 at a time, a new order is created after the first page
+	walk := func(sort string) []int64 {
+		var ids []int64
+		filter := OrderFilter{Sort: sort}
+		for page := 0; page < 10; page++ {
+			orders, err := repo.List(context.Background(), filter, 0, 2)
+			a.Nil(err, "orders should be listed without err")
+			if len(orders) == 0 {
+				break
+			}
+			for _, o := range orders {
+				ids = append(ids, o.ID)
+			}
+			if page == 0 {
+				created = append(created, createTestOrder(t, repo, 0).ID)
+			}
+
+			after := CursorOf(orders[len(orders)-1])
+			filter.After = &after
+		}
+		return ids
+	}
+
+	// the new order is after the cursor so it is on a later page
+	ids := walk(SortCreatedAt)
+	a.Equal(created, ids, "orders should be listed once in the order they were created")
+
+	// the new order is before the cursor so it is left out instead of shifting the pages
+	expect := []int64{created[3], created[2], created[1], created[0]}
+	ids = walk(SortCreatedAtDesc)
+	a.Equal(expect, ids, "orders should be listed once from the newest")
+}
+
+// test for take move the order to taken
+func testRepositoryTake(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+
+	err := repo.Take(context.Background(), created.ID, testCourierID)
+	a.Nil(err, "order should be taken without err")
+
+	o, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusTaken, o.Status, "status should be TAKEN")
+	a.Equal(testCourierID, o.AssigneeID, "courier should be the assignee")
+	if a.NotNil(o.TakenAt, "taken time should be set") {
+		a.WithinDuration(time.Now(), *o.TakenAt, 5*time.Second, "taken time should be now")
+	}
+}
+
+// test for take an order which is already taken
+func testRepositoryTakeAlreadyTaken(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+
+	a.Nil(repo.Take(context.Background(), created.ID, testCourierID), "first take should succeed")
+	a.Equal(e.ErrOrderAlreadyTaken, repo.Take(context.Background(), created.ID, testCourierID), "second take should be already taken")
+}
+
+// test for take an order which does not exist
+func testRepositoryTakeNotExist(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	a.Equal(e.ErrOrderNotExist, repo.Take(context.Background(), 12345, testCourierID), "error should be order not exist")
+}
+
+// test that only one of many parallel takes wins
+func testRepositoryTakeConcurrent(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		winners int
+		losers  int
+		others  []error
+	)
+
+	// fire all the takes at the same time
+	start := make(chan struct{})
+	for i := 0; i < concurrentTakers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			err := repo.Take(context.Background(), created.ID, testCourierID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				winners++
+			case e.ErrOrderAlreadyTaken:
+				losers++
+			default:
+				others = append(others, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// check exactly one caller won
+	a.Empty(others, "no take should fail with unexpected error")
+	a.Equal(1, winners, "exactly one take should succeed")
+	a.Equal(concurrentTakers-1, losers, "every other take should get already taken")
+}
+
+// test for release give the order back to the pool and record it
+func testRepositoryRelease(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+	if err := repo.Take(context.Background(), created.ID, testCourierID); err != nil {
+		t.Fatal(err)
+	}
+
+	err := repo.Release(context.Background(), created.ID, testCourierID)
+	a.Nil(err, "order should be released without err")
+
+	o, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusUnassigned, o.Status, "status should be UNASSIGNED")
+	a.Empty(o.AssigneeID, "assignee should be cleared")
+	a.Nil(o.TakenAt, "taken time should be cleared")
+	if a.Len(o.Releases, 1, "release should be recorded") {
+		a.Equal(testCourierID, o.Releases[0].CourierID, "release should name the courier")
+		a.WithinDuration(time.Now(), o.Releases[0].CreatedAt, 5*time.Second, "release time should be now")
+	}
+
+	// another courier can take it again
+	a.Nil(repo.Take(context.Background(), created.ID, "courier-2"), "released order should be taken again")
+	o, _ = repo.Get(context.Background(), created.ID)
+	a.Equal("courier-2", o.AssigneeID, "new courier should be the assignee")
+	a.Len(o.Releases, 1, "history should be kept")
+}
+
+// test for release of an order another courier has taken
+func testRepositoryReleaseNotAssignee(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+	if err := repo.Take(context.Background(), created.ID, testCourierID); err != nil {
+		t.Fatal(err)
+	}
+
+	err := repo.Release(context.Background(), created.ID, "courier-2")
+	a.Equal(e.ErrOrderNotAssignee, err, "error should be not assignee")
+
+	o, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusTaken, o.Status, "status should stay taken")
+	a.Equal(testCourierID, o.AssigneeID, "assignee should be kept")
+	a.Empty(o.Releases, "release should not be recorded")
+}
+
+// test for release of an order which is not taken or does not exist
+func testRepositoryReleaseNotTaken(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+
+	err := repo.Release(context.Background(), created.ID, testCourierID)
+	a.Equal(&e.TransitionError{Current: StatusUnassigned, Requested: StatusUnassigned}, err, "error should name the current status")
+
+	err = repo.Release(context.Background(), 12345, testCourierID)
+	a.Equal(e.ErrOrderNotExist, err, "error should be order not exist")
+}
+
+// test for update status move the order and record the time
+func testRepositoryUpdateStatus(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+
+	err := repo.UpdateStatus(context.Background(), created.ID, StatusUnassigned, StatusCancelled, testCourierID)
+	a.Nil(err, "order should be updated without err")
+
+	o, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusCancelled, o.Status, "status should be updated")
+	if a.NotNil(o.CancelledAt, "cancel time should be set") {
+		a.WithinDuration(time.Now(), *o.CancelledAt, 5*time.Second, "cancel time should be now")
+	}
+	a.Nil(o.TakenAt, "taken time should not be set")
+}
+
+// test for update status of an order which is no longer in the from status
+func testRepositoryUpdateStatusConflict(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+	if err := repo.Take(context.Background(), created.ID, testCourierID); err != nil {
+		t.Fatal(err)
+	}
+
+	err := repo.UpdateStatus(context.Background(), created.ID, StatusUnassigned, StatusCancelled, testCourierID)
+
+	a.Equal(&e.TransitionError{Current: StatusTaken, Requested: StatusCancelled}, err, "error should name the current status")
+
+	o, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusTaken, o.Status, "status should stay taken")
+	a.Nil(o.CancelledAt, "cancel time should not be set")
+}
+
+// test for update status of an order which does not exist
+func testRepositoryUpdateStatusNotExist(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	err := repo.UpdateStatus(context.Background(), 12345, StatusUnassigned, StatusCancelled, testCourierID)
+
+	a.Equal(e.ErrOrderNotExist, err, "error should be order not exist")
+}
+
+// test for update status of an order which changed hands after it was read
+func testRepositoryUpdateStatusNotAssignee(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+	if err := repo.Take(context.Background(), created.ID, testCourierID); err != nil {
+		t.Fatal(err)
+	}
+
+	// the courier reads the order while they still hold it
+	read, _ := repo.Get(context.Background(), created.ID)
+
+	// then releases it and another courier takes it
+	a.Nil(repo.Release(context.Background(), created.ID, testCourierID), "order should be released")
+	a.Nil(repo.Take(context.Background(), created.ID, "courier-2"), "order should be taken by another courier")
+
+	err := repo.UpdateStatus(context.Background(), created.ID, read.Status, StatusPickedUp, testCourierID)
+	a.Equal(e.ErrOrderNotAssignee, err, "error should be not assignee")
+
+	o, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusTaken, o.Status, "status should stay taken")
+	a.Equal("courier-2", o.AssigneeID, "assignee should be kept")
+	a.Nil(o.PickedUpAt, "pick up time should not be set")
+
+	// the courier holding it moves it on
+	a.Nil(repo.UpdateStatus(context.Background(), created.ID, StatusTaken, StatusPickedUp, "courier-2"), "assignee should move the order on")
+}
+
+// test for cancel record who cancelled the order and why
+func testRepositoryCancel(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+
+	c := Cancellation{By: "support-42", Reason: CancelReasonCustomerRequest, Note: "ordered twice"}
+	err := repo.Cancel(context.Background(), created.ID, StatusUnassigned, c)
+	a.Nil(err, "order should be cancelled without err")
+
+	o, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusCancelled, o.Status, "status should be cancelled")
+	a.NotNil(o.CancelledAt, "cancel time should be set")
+	a.Equal("support-42", o.CancelledBy, "who cancelled should be stored")
+	a.Equal(CancelReasonCustomerRequest, o.CancelReason, "reason should be stored")
+	a.Equal("ordered twice", o.CancelNote, "note should be stored")
+
+	// the order is no longer unassigned
+	err = repo.Cancel(context.Background(), created.ID, StatusUnassigned, c)
+	a.Equal(&e.TransitionError{Current: StatusCancelled, Requested: StatusCancelled}, err, "error should name the current status")
+}
+
+// test for count the orders grouped by status
+func testRepositoryCountByStatus(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	counts, err := repo.CountByStatus(context.Background())
+	a.Nil(err, "orders should be counted without err")
+	a.Equal(0, len(counts), "empty repository should have no status")
+
+	for i := 0; i < 3; i++ {
+		createTestOrder(t, repo, i)
+	}
+	taken := createTestOrder(t, repo, 100)
+	a.Nil(repo.Take(context.Background(), taken.ID, testCourierID), "order should be taken without err")
+
+	counts, err = repo.CountByStatus(context.Background())
+	a.Nil(err, "orders should be counted without err")
+	a.Equal(map[string]int64{StatusUnassigned: 3, StatusTaken: 1}, counts, "orders should be counted by status")
+}
+
+// test that nothing is stored or changed once the context is canceled
+func testRepositoryCanceled(t *testing.T, repo OrderRepository) {
+	a := assert.New(t)
+
+	created := createTestOrder(t, repo, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	o := Order{Distance: 200, Status: StatusUnassigned}
+	a.Equal(context.Canceled, repo.Create(ctx, &o), "create should return the context error")
+	a.Equal(context.Canceled, repo.Take(ctx, created.ID, testCourierID), "take should return the context error")
+	a.Equal(context.Canceled, repo.UpdateStatus(ctx, created.ID, StatusUnassigned, StatusCancelled, testCourierID), "update status should return the context error")
+	a.Equal(context.Canceled, repo.Release(ctx, created.ID, testCourierID), "release should return the context error")
+	a.Equal(context.Canceled, repo.Cancel(ctx, created.ID, StatusUnassigned, Cancellation{}), "cancel should return the context error")
+
+	_, err := repo.Get(ctx, created.ID)
+	a.Equal(context.Canceled, err, "get should return the context error")
+	_, err = repo.List(ctx, OrderFilter{}, 0, 10)
+	a.Equal(context.Canceled, err, "list should return the context error")
+	_, err = repo.CountByStatus(ctx)
+	a.Equal(context.Canceled, err, "count should return the context error")
+
+	// check nothing has changed
+	orders, _ := repo.List(context.Background(), OrderFilter{}, 0, 10)
+	a.Equal(1, len(orders), "canceled create should not store the order")
+	stored, _ := repo.Get(context.Background(), created.ID)
+	a.Equal(StatusUnassigned, stored.Status, "canceled take should not change the status")
+}
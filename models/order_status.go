@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+const (
+	StatusUnassigned = "UNASSIGNED"
+	StatusTaken      = "TAKEN"
+	StatusPickedUp   = "PICKED_UP"
+	StatusDelivered  = "DELIVERED"
+	StatusCancelled  = "CANCELLED"
+	StatusFailed     = "FAILED"
+)
+
+// every status of an order in the order of the lifecycle
+var Statuses = []string{StatusUnassigned, StatusTaken, StatusPickedUp, StatusDelivered, StatusCancelled, StatusFailed}
+
+// reasons an order is cancelled for
+const (
+	CancelReasonCustomerRequest    = "CUSTOMER_REQUEST"
+	CancelReasonCourierUnavailable = "COURIER_UNAVAILABLE"
+	CancelReasonAddressInvalid     = "ADDRESS_INVALID"
+	CancelReasonDuplicate          = "DUPLICATE"
+	CancelReasonOther              = "OTHER"
+)
+
+// every reason an order can be cancelled for
+var CancelReasons = []string{
+	CancelReasonCustomerRequest,
+	CancelReasonCourierUnavailable,
+	CancelReasonAddressInvalid,
+	CancelReasonDuplicate,
+	CancelReasonOther,
+}
+
+// struct for who cancelled an order and why
+type Cancellation struct {
+	By     string
+	Reason string
+	Note   string
+}
+
+// statuses an order can move to from its status, the ones missing are final
+var transitions = map[string][]string{
+	StatusUnassigned: {StatusTaken, StatusCancelled},
+	StatusTaken:      {StatusUnassigned, StatusPickedUp, StatusCancelled, StatusFailed},
+	StatusPickedUp:   {StatusDelivered, StatusFailed},
+}
+
+// column of the time an order reached the status
+var statusTimeColumns = map[string]string{
+	StatusTaken:     "taken_at",
+	StatusPickedUp:  "picked_up_at",
+	StatusDelivered: "delivered_at",
+	StatusCancelled: "cancelled_at",
+	StatusFailed:    "failed_at",
+}
+
+// check if an order can move from one status to another
+func CanTransition(from string, to string) bool {
+	return contains(transitions[from], to)
+}
+
+// function to move the order to the status and record the time it reached it
+func (o *Order) setStatus(status string, now time.Time) {
+	o.Status = status
+	o.UpdatedAt = now
+
+	switch status {
+	case StatusTaken:
+		o.TakenAt = &now
+	case StatusPickedUp:
+		o.PickedUpAt = &now
+	case StatusDelivered:
+		o.DeliveredAt = &now
+	case StatusCancelled:
+		o.CancelledAt = &now
+	case StatusFailed:
+		o.FailedAt = &now
+	}
+}
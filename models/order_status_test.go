@@ -0,0 +1,60 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// test for every transition of the lifecycle against the expected table
+func TestCanTransition(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	allowed := map[string]map[string]bool{
+		StatusUnassigned: {StatusTaken: true, StatusCancelled: true},
+		StatusTaken:      {StatusUnassigned: true, StatusPickedUp: true, StatusCancelled: true, StatusFailed: true},
+		StatusPickedUp:   {StatusDelivered: true, StatusFailed: true},
+		StatusDelivered:  {},
+		StatusCancelled:  {},
+		StatusFailed:     {},
+	}
+
+	for _, from := range Statuses {
+		for _, to := range Statuses {
+			a.Equal(allowed[from][to], CanTransition(from, to), "transition from %s to %s should match the table", from, to)
+		}
+	}
+
+	a.False(CanTransition("SUCCESS", StatusTaken), "unknown status should not move")
+	a.False(CanTransition(StatusUnassigned, "SUCCESS"), "order should not move to an unknown status")
+}
+
+// test for the time the order reached the status is recorded
+func TestOrder_SetStatus(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	now := time.Now()
+	times := map[string]func(o *Order) *time.Time{
+		StatusTaken:     func(o *Order) *time.Time { return o.TakenAt },
+		StatusPickedUp:  func(o *Order) *time.Time { return o.PickedUpAt },
+		StatusDelivered: func(o *Order) *time.Time { return o.DeliveredAt },
+		StatusCancelled: func(o *Order) *time.Time { return o.CancelledAt },
+		StatusFailed:    func(o *Order) *time.Time { return o.FailedAt },
+	}
+
+	for status, at := range times {
+		var o Order
+		o.setStatus(status, now)
+
+		a.Equal(status, o.Status, "status should be set")
+		a.Equal(now, o.UpdatedAt, "update time should be set")
+		if a.NotNil(at(&o), "time of %s should be set", status) {
+			a.Equal(now, *at(&o), "time of %s should be the time of the change", status)
+		}
+		a.Contains(statusTimeColumns, status, "column of %s should be known", status)
+	}
+}
@@ -1,34 +1,48 @@
 package models
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"github.com/jinzhu/gorm"
 	mocket "github.com/selvatico/go-mocket"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
 	"order-service/pkgs/e"
 	"order-service/services/distance"
+	"order-service/services/geocoding"
 	"testing"
+	"time"
 )
 
 var ErrBadDriver = errors.New("driver: bad connection")
 
+// calculator counting the calls which reach it
+type recordingCalculator struct {
+	calls int
+	src   []string
+	des   []string
+}
+
+func (c *recordingCalculator) Calculate(ctx context.Context, src []string, des []string, opts distance.Options) (distance.Result, error) {
+	c.calls++
+	c.src, c.des = src, des
+	return distance.Result{Distance: 1234, Provider: "recording"}, nil
+}
+
 // test for success create an order
 func TestCreateOrder(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	db := NewMockDB()
 
 	var (
 		expectDistance = rand.Intn(100)
 		expectedId     = rand.Int63n(100)
 	)
-	// init the mock calculator
-	distance.InitMockCalculator(expectDistance, nil)
+	// init the order service with the mock calculator
+	svc := NewOrderService(NewGormOrderRepository(db), distance.NewMockCalculator(expectDistance, nil), nil)
 
 	// mock the query that create order
-	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "orders" ("distance","status") VALUES (?,?)`).WithID(expectedId)
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "orders"`).WithID(expectedId)
 	defer mocket.Catcher.Reset()
 
 	var (
@@ -36,30 +50,172 @@ func TestCreateOrder(t *testing.T) {
 		des = []string{"1.5", "1.6"}
 	)
 
-	o, err := CreateOrder(src, des)
+	o, err := svc.CreateOrder(context.Background(), Endpoint{Coordinate: src}, Endpoint{Coordinate: des}, distance.Options{})
 
 	// check if the order return without error
 	a.Nil(err, "order should be created without err")
 	a.NotNil(o, "order should be created")
 	a.Equal(expectedId, o.ID, "id should be as expected")
 	a.Equal(expectDistance, o.Distance, "distance should be as expected")
+	a.Equal(distance.ProviderMock, o.DistanceProvider, "provider of the distance should be stored")
 	a.Equal(StatusUnassigned, o.Status, "status should be UNASSIGNED")
+	a.Equal(1.0, *o.Origin.Lat, "origin latitude should be stored")
+	a.Equal(2.0, *o.Origin.Lng, "origin longitude should be stored")
+	a.Equal(1.5, *o.Destination.Lat, "destination latitude should be stored")
+	a.Equal(1.6, *o.Destination.Lng, "destination longitude should be stored")
+	a.False(o.CreatedAt.IsZero(), "creation time should be set")
+	a.Nil(o.TakenAt, "taken time should not be set")
+}
+
+// test for create order with an address instead of coordinates
+func TestCreateOrder_Address(t *testing.T) {
+	a := assert.New(t)
+
+	db := NewMockDB()
+
+	// init the order service with the mock calculator
+	svc := NewOrderService(NewGormOrderRepository(db), distance.NewMockCalculator(rand.Intn(100), nil), nil)
+
+	// mock the query that create order
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "orders"`).WithID(rand.Int63n(100))
+	defer mocket.Catcher.Reset()
+
+	var (
+		src = []string{"1600 Amphitheatre Pkwy", "Mountain View"}
+		des = []string{"1.5", "1.6"}
+	)
+
+	o, err := svc.CreateOrder(context.Background(), Endpoint{Coordinate: src}, Endpoint{Coordinate: des}, distance.Options{})
+
+	// check the origin is left empty since it is not a coordinate
+	a.Nil(err, "order should be created without err")
+	a.Nil(o.Origin.Lat, "origin latitude should be empty")
+	a.Nil(o.Origin.Lng, "origin longitude should be empty")
+	a.Equal(1.5, *o.Destination.Lat, "destination latitude should be stored")
+}
+
+// test for create order with the addresses resolved by the geocoder
+func TestCreateOrder_Geocode(t *testing.T) {
+	a := assert.New(t)
+
+	geocoder := geocoding.NewFakeGeocoder(map[string]geocoding.Place{
+		"1600 Amphitheatre Pkwy, Mountain View": {Lat: 37.4224, Lng: -122.0842, FormattedAddress: "1600 Amphitheatre Pkwy, Mountain View, CA 94043, USA"},
+	})
+	calc := &recordingCalculator{}
+	svc := NewOrderService(NewMemoryOrderRepository(), calc, geocoder)
+
+	src := Endpoint{Address: "1600  amphitheatre pkwy, mountain view"}
+	des := Endpoint{Coordinate: []string{"1.5", "1.6"}}
+
+	o, err := svc.CreateOrder(context.Background(), src, des, distance.Options{})
+
+	// check both the formatted address and the coordinate are stored
+	a.Nil(err, "order should be created without err")
+	a.Equal("1600 Amphitheatre Pkwy, Mountain View, CA 94043, USA", o.Origin.Address, "formatted address should be stored")
+	a.Equal(37.4224, *o.Origin.Lat, "origin latitude should come from the geocoder")
+	a.Equal(-122.0842, *o.Origin.Lng, "origin longitude should come from the geocoder")
+	a.Empty(o.Destination.Address, "destination address should be empty")
+	a.Equal(1.5, *o.Destination.Lat, "destination latitude should be stored")
+	a.Equal([]string{"37.4224", "-122.0842"}, calc.src, "distance should be calculated from the resolved coordinate")
+	a.Equal(des.Coordinate, calc.des, "distance should be calculated to the given coordinate")
+}
+
+// test for create order with stops between the origin and the destination
+func TestCreateOrder_Stops(t *testing.T) {
+	a := assert.New(t)
+
+	calc := &recordingCalculator{}
+	geocoder := geocoding.NewFakeGeocoder(map[string]geocoding.Place{
+		"Henderson": {Lat: 36.0395, Lng: -114.9817, FormattedAddress: "Henderson, NV, USA"},
+	})
+	svc := NewOrderService(NewMemoryOrderRepository(), calc, geocoder)
+
+	src := Endpoint{Coordinate: []string{"1", "2"}}
+	des := Endpoint{Coordinate: []string{"1.5", "1.6"}}
+	stops := []Endpoint{{Address: "Henderson"}, {Coordinate: []string{"3", "4"}}}
+
+	o, err := svc.CreateOrder(context.Background(), src, des, distance.Options{}, stops...)
+
+	a.Nil(err, "order should be created without err")
+	a.Equal(3, calc.calls, "distance should be calculated for every leg")
+	a.Equal([]string{"3", "4"}, calc.src, "last leg should start at the last stop")
+	a.Equal(3*1234, o.Distance, "distance should be the sum of the legs")
+	a.Equal("recording", o.DistanceProvider, "provider should be listed once")
+	if a.Len(o.Stops, 2, "stops should be stored") {
+		a.Equal("Henderson, NV, USA", o.Stops[0].Location.Address, "address of the stop should be geocoded")
+		a.Equal(1, o.Stops[1].Sequence, "stops should keep their order")
+		a.Equal(3.0, *o.Stops[1].Location.Lat, "coordinate of the stop should be stored")
+	}
+	if a.Len(o.Legs, 3, "legs should be stored") {
+		a.Equal(2, o.Legs[2].Sequence, "legs should be in the sequence")
+		a.Equal(1234, o.Legs[2].Distance, "leg should have its own distance")
+	}
+	a.Nil(o.DurationSeconds, "duration should be unknown when a leg has none")
+}
+
+// test for the duration of the order is the sum of the legs
+func TestCreateOrder_Stops_Duration(t *testing.T) {
+	a := assert.New(t)
+
+	calc := distance.NewMockResultCalculator(distance.Result{Distance: 100, Duration: 90 * time.Second, Provider: distance.ProviderMock})
+	svc := NewOrderService(NewMemoryOrderRepository(), calc, nil)
+
+	src := Endpoint{Coordinate: []string{"1", "2"}}
+	des := Endpoint{Coordinate: []string{"1.5", "1.6"}}
+	stop := Endpoint{Coordinate: []string{"3", "4"}}
+
+	o, err := svc.CreateOrder(context.Background(), src, des, distance.Options{}, stop)
+
+	a.Nil(err, "order should be created without err")
+	a.Equal(200, o.Distance, "distance should be the sum of the legs")
+	a.Equal(180, *o.DurationSeconds, "duration should be the sum of the legs")
+	a.Equal(90, *o.Legs[1].DurationSeconds, "leg should have its own duration")
+}
+
+// test for create order with an address the geocoder does not know
+func TestCreateOrder_Address_Not_Found(t *testing.T) {
+	a := assert.New(t)
+
+	calc := &recordingCalculator{}
+	svc := NewOrderService(NewMemoryOrderRepository(), calc, geocoding.NewFakeGeocoder(nil))
+
+	src := Endpoint{Coordinate: []string{"1", "2"}}
+	des := Endpoint{Address: "nowhere"}
+
+	o, err := svc.CreateOrder(context.Background(), src, des, distance.Options{})
+
+	a.Nil(o, "order should not be created")
+	if a.IsType(&AddressNotFoundError{}, err, "error should be address not found") {
+		a.Equal("destination", err.(*AddressNotFoundError).Endpoint, "destination should not be found")
+	}
+	a.Equal(0, calc.calls, "distance should not be calculated")
+}
+
+// test for create order with an address while there is no geocoder
+func TestCreateOrder_Geocoding_Disabled(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil)
+
+	_, err := svc.CreateOrder(context.Background(), Endpoint{Address: "Las Vegas"}, Endpoint{Coordinate: []string{"1", "2"}}, distance.Options{})
+
+	a.Equal(e.ErrGeocodingDisabled, err, "error should be geocoding disabled")
 }
 
 // test for create order when distance service return unknown distance error
 func TestCreateOrder_Unknown_Distance(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	db := NewMockDB()
 
 	var (
 		expectedId     = rand.Int63n(100)
 	)
-	// init the mock calculator
-	distance.InitMockCalculator(0, e.ErrDistanceUnknown)
+	// init the order service with the mock calculator
+	svc := NewOrderService(NewGormOrderRepository(db), distance.NewMockCalculator(0, e.ErrDistanceUnknown), nil)
 
 	// mock the query that create order
-	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "orders" ("distance","status") VALUES (?,?)`).WithID(expectedId)
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "orders"`).WithID(expectedId)
 	defer mocket.Catcher.Reset()
 
 	var (
@@ -67,7 +223,7 @@ func TestCreateOrder_Unknown_Distance(t *testing.T) {
 		des = []string{"1.5", "1.6"}
 	)
 
-	o, err := CreateOrder(src, des)
+	o, err := svc.CreateOrder(context.Background(), Endpoint{Coordinate: src}, Endpoint{Coordinate: des}, distance.Options{})
 
 	// check if correct error returned
 	a.NotNil(err, "error should occur based on the request")
@@ -79,14 +235,14 @@ func TestCreateOrder_Unknown_Distance(t *testing.T) {
 func TestCreateOrder_Query_Exception(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	db := NewMockDB()
 
 	// init the mock calculator
 	var expectDistance = 100
-	distance.InitMockCalculator(expectDistance, nil)
+	svc := NewOrderService(NewGormOrderRepository(db), distance.NewMockCalculator(expectDistance, nil), nil)
 
 	// mock the query that create order with exception
-	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "orders" ("distance","status") VALUES (?,?)`).WithExecException()
+	mocket.Catcher.NewMock().WithQuery(`INSERT  INTO "orders"`).WithExecException()
 	defer mocket.Catcher.Reset()
 
 	var (
@@ -94,7 +250,7 @@ func TestCreateOrder_Query_Exception(t *testing.T) {
 		des = []string{"1.5", "1.6"}
 	)
 
-	o, err := CreateOrder(src, des)
+	o, err := svc.CreateOrder(context.Background(), Endpoint{Coordinate: src}, Endpoint{Coordinate: des}, distance.Options{})
 
 	// check if correct error returned
 	a.NotNil(err, "error should occur based on the query")
@@ -106,18 +262,18 @@ func TestCreateOrder_Query_Exception(t *testing.T) {
 func TestCreateOrder_Service_Exception(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	db := NewMockDB()
 
 	// init the mock calculator with error
 	var expectedErr = errors.New("test for service exception")
-	distance.InitMockCalculator(0, expectedErr)
+	svc := NewOrderService(NewGormOrderRepository(db), distance.NewMockCalculator(0, expectedErr), nil)
 
 	var (
 		src = []string{"1", "2"}
 		des = []string{"1.5", "1.6"}
 	)
 
-	o, err := CreateOrder(src, des)
+	o, err := svc.CreateOrder(context.Background(), Endpoint{Coordinate: src}, Endpoint{Coordinate: des}, distance.Options{})
 
 	// check if correct error returned
 	a.Equal(expectedErr, err, "error should the expected error")
@@ -128,23 +284,33 @@ func TestCreateOrder_Service_Exception(t *testing.T) {
 func TestGetOrders(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
 
 	// set up expected result
+	lat, lng := 36.0222811, -115.0980736
+	createdAt := time.Date(2019, 7, 1, 10, 0, 0, 0, time.UTC)
+	takenAt := createdAt.Add(time.Hour)
 	order1 := Order{ID: 1, Status: StatusUnassigned, Distance: rand.Intn(5000)}
-	order2 := Order{ID: 2, Status: StatusTaken, Distance: rand.Intn(5000)}
+	order2 := Order{
+		ID:          2,
+		Origin:      Location{Lat: &lat, Lng: &lng},
+		Destination: Location{Lat: &lng, Lng: &lat},
+		Status:      StatusTaken,
+		Distance:    rand.Intn(5000),
+		CreatedAt:   createdAt,
+		UpdatedAt:   takenAt,
+		TakenAt:     &takenAt,
+	}
 	orders := []Order{order1, order2}
 
 	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
+	expectMap := MockOrderRows(orders...)
 
 	// mock the query that query the orders
-	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"   LIMIT 1 OFFSET 1`).WithReply(expectMap)
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"   ORDER BY id ASC LIMIT 1 OFFSET 1`).WithReply(expectMap)
 	defer mocket.Catcher.Reset()
 
-	results, err := GetOrders(1, 1)
+	results, err := svc.GetOrders(context.Background(), OrderFilter{}, 1, 1)
 
 	// check if the order return without error
 	a.Nil(err, "order should be created without err")
@@ -154,17 +320,73 @@ func TestGetOrders(t *testing.T) {
 	a.Equal(order2, *results[1], "order 2 should match exactly")
 }
 
+// test for get orders with the filter and the sort in the query
+func TestGetOrders_Filter(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
+
+	order1 := Order{ID: 1, Status: StatusTaken, Distance: 1200, AssigneeID: "courier-1"}
+
+	// mock the query with the arguments of the conditions
+	query := `SELECT * FROM "orders"  WHERE (status = TAKEN) AND (assignee_id = courier-1) AND (distance >= 1000) ` +
+		`ORDER BY distance DESC, id DESC LIMIT 10 OFFSET 0`
+	mocket.Catcher.NewMock().WithQuery(query).WithReply(MockOrderRows(order1))
+	defer mocket.Catcher.Reset()
+
+	min := 1000
+	filter := OrderFilter{Status: StatusTaken, AssigneeID: "courier-1", MinDistance: &min, Sort: SortDistanceDesc}
+	results, err := svc.GetOrders(context.Background(), filter, 0, 10)
+
+	// check the query matched the filter
+	a.Nil(err, "orders should be listed without err")
+	if a.Len(results, 1, "order should be listed") {
+		a.Equal(order1, *results[0], "order should match exactly")
+	}
+}
+
+// test for get orders after the cursor hand out the next cursor until the last page
+func TestGetOrdersAfter(t *testing.T) {
+	a := assert.New(t)
+
+	repo := NewMemoryOrderRepository()
+	svc := NewOrderService(repo, distance.NewMockCalculator(0, nil), nil)
+	for i := 0; i < 3; i++ {
+		createTestOrder(t, repo, i)
+	}
+
+	filter := OrderFilter{Sort: SortCreatedAt}
+	first, next, err := svc.GetOrdersAfter(context.Background(), filter, 2)
+	a.Nil(err, "orders should be listed without err")
+	a.Len(first, 2, "first page should be full")
+	if a.NotNil(next, "first page should have a next cursor") {
+		a.Equal(CursorOf(first[1]), *next, "next cursor should be at the last order of the page")
+	}
+
+	filter.After = next
+	last, next, err := svc.GetOrdersAfter(context.Background(), filter, 2)
+	a.Nil(err, "orders should be listed without err")
+	a.Len(last, 1, "last page should have the rest")
+	a.Nil(next, "last page should not have a next cursor")
+
+	// an exactly full last page
+	filter.After = nil
+	all, next, _ := svc.GetOrdersAfter(context.Background(), filter, 3)
+	a.Len(all, 3, "every order should fit the page")
+	a.Nil(next, "full last page should not have a next cursor")
+}
+
 // test for get orders when db return query exception
 func TestGetOrders_Query_Exception(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
 
 	// mock the query that query the orders with exception
-	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"   LIMIT 1 OFFSET 1`).WithQueryException()
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"   ORDER BY id ASC LIMIT 1 OFFSET 1`).WithQueryException()
 	defer mocket.Catcher.Reset()
 
-	os, err := GetOrders(1, 1)
+	os, err := svc.GetOrders(context.Background(), OrderFilter{}, 1, 1)
 
 	// check if correct error returned
 	a.NotNil(err, "error should occur based on the query")
@@ -172,11 +394,67 @@ func TestGetOrders_Query_Exception(t *testing.T) {
 	a.Nil(os, "order should not be returned")
 }
 
+// test for successful get order
+func TestGetOrder(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
+
+	// set up expected result
+	const orderId = 1
+	order1 := Order{ID: orderId, Status: StatusUnassigned, Distance: rand.Intn(5000)}
+
+	// mock the query that get the order by id
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE (id = 1)`).WithReply(MockOrderRows(order1))
+	defer mocket.Catcher.Reset()
+
+	o, err := svc.GetOrder(context.Background(), orderId)
+
+	// check if the order return without error
+	a.Nil(err, "error should be nil")
+	a.NotNil(o, "order should be returned")
+	a.Equal(order1, *o, "order should match exactly")
+}
+
+// test for get order when this order does not exist
+func TestGetOrder_Not_Exist(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
+
+	// mock the query that get the order by id
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`)
+	defer mocket.Catcher.Reset()
+
+	o, err := svc.GetOrder(context.Background(), rand.Int63n(100))
+
+	// check if correct error returned
+	a.Equal(e.ErrOrderNotExist, err, "error should be order not exist")
+	a.Nil(o, "order should not be returned")
+}
+
+// test for get order when db return query exception
+func TestGetOrder_Query_Exception(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
+
+	// mock the query that get the order by id with exception
+	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`).WithQueryException()
+	defer mocket.Catcher.Reset()
+
+	o, err := svc.GetOrder(context.Background(), rand.Int63n(100))
+
+	// check if correct error returned
+	a.Equal(ErrBadDriver, err, "error should the expected error")
+	a.Nil(o, "order should not be returned")
+}
+
 // test for successful take order
-func TestTakeOrder(t *testing.T) {
+func TestUpdateStatus_Take(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
 
 	// set up expected result
 	const orderId = 1
@@ -184,31 +462,32 @@ func TestTakeOrder(t *testing.T) {
 	orders := []Order{order1}
 
 	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
+	expectMap := MockOrderRows(orders...)
 
 	// mock the query that get the order by id
 	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`).WithReply(expectMap)
 	defer mocket.Catcher.Reset()
 
-	err := TakeOrder(orderId)
+	// mock the query which update the order only when unassigned
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "orders" SET "assignee_id" = ?, "status" = ?, "taken_at" = ?, "updated_at" = ?  WHERE (id = ? AND status = ?)`).WithRowsNum(1)
+
+	err := svc.UpdateStatus(context.Background(), orderId, StatusTaken, testCourierID)
 
 	// check if return without error
 	a.Nil(err, "error should be nil")
 }
 
 // test for take order when db has exception on select statement
-func TestTakeOrder_Query_Exception_On_Select(t *testing.T) {
+func TestUpdateStatus_Take_Query_Exception_On_Select(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
 
 	// mock the query that get the order by id with exception
 	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`).WithQueryException()
 	defer mocket.Catcher.Reset()
 
-	err := TakeOrder(rand.Int63n(100))
+	err := svc.UpdateStatus(context.Background(), rand.Int63n(100), StatusTaken, testCourierID)
 
 	// check if correct error returned
 	a.NotNil(err, "error should be returned")
@@ -216,10 +495,10 @@ func TestTakeOrder_Query_Exception_On_Select(t *testing.T) {
 }
 
 // test for take order when db has exception on update statement
-func TestTakeOrder_Query_Exception_On_Update(t *testing.T) {
+func TestUpdateStatus_Take_Query_Exception_On_Update(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
 
 	// set up expected result
 	const orderId = 1
@@ -227,18 +506,16 @@ func TestTakeOrder_Query_Exception_On_Update(t *testing.T) {
 	orders := []Order{order1}
 
 	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
+	expectMap := MockOrderRows(orders...)
 
 	// mock the query that get the order by id
 	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`).WithReply(expectMap)
 	defer mocket.Catcher.Reset()
 
 	// mock the query which update the order
-	mocket.Catcher.NewMock().WithQuery(`UPDATE "orders" SET "status" = ?  WHERE "orders"."id" = ?`).WithExecException()
+	mocket.Catcher.NewMock().WithQuery(`UPDATE "orders" SET "assignee_id" = ?, "status" = ?, "taken_at" = ?, "updated_at" = ?  WHERE (id = ? AND status = ?)`).WithExecException()
 
-	err := TakeOrder(orderId)
+	err := svc.UpdateStatus(context.Background(), orderId, StatusTaken, testCourierID)
 
 	// check if correct error returned
 	a.NotNil(err, "error should be returned")
@@ -246,10 +523,10 @@ func TestTakeOrder_Query_Exception_On_Update(t *testing.T) {
 }
 
 // test for take order when this order is already taken
-func TestTakeOrder_Already_Taken(t *testing.T) {
+func TestUpdateStatus_Take_Already_Taken(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
 
 	// set up expected result
 	const orderId = 1
@@ -257,34 +534,227 @@ func TestTakeOrder_Already_Taken(t *testing.T) {
 	orders := []Order{order1}
 
 	// make the struct into map for the database mock
-	var expectMap []map[string]interface{}
-	i, _ := json.Marshal(orders)
-	_ = json.Unmarshal(i, &expectMap)
+	expectMap := MockOrderRows(orders...)
 
 	// mock the query that get the order by id
 	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`).WithReply(expectMap)
 	defer mocket.Catcher.Reset()
 
-	err := TakeOrder(orderId)
+	err := svc.UpdateStatus(context.Background(), orderId, StatusTaken, testCourierID)
 
 	// check if correct error returned
 	a.NotNil(err, "error should be returned")
-	a.Equal(e.ErrOrderAlreadyTaken, err, "error should not found from gorm")
+	a.Equal(&e.TransitionError{Current: StatusTaken, Requested: StatusTaken}, err, "error should be the transition error")
+	a.Equal(e.ErrOrderAlreadyTaken.Error(), err.Error(), "error should say the order is already taken")
 }
 
 // test for take order when this order does not exist
-func TestTakeOrder_Not_Exist(t *testing.T) {
+func TestUpdateStatus_Take_Not_Exist(t *testing.T) {
 	a := assert.New(t)
 
-	InitMockModel()
+	svc := NewOrderService(NewGormOrderRepository(NewMockDB()), distance.NewMockCalculator(0, nil), nil)
 
 	// mock the query that get the order by id
 	mocket.Catcher.NewMock().WithQuery(`SELECT * FROM "orders"  WHERE`)
 	defer mocket.Catcher.Reset()
 
-	err := TakeOrder(rand.Int63n(100))
+	err := svc.UpdateStatus(context.Background(), rand.Int63n(100), StatusTaken, testCourierID)
 
 	// check if correct error returned
 	a.NotNil(err, "error should be returned")
-	a.Equal(gorm.ErrRecordNotFound, err, "error should not found from gorm")
+	a.Equal(e.ErrOrderNotExist, err, "error should be order not exist")
+}
+
+// test for update status through the whole lifecycle of a delivery
+func TestUpdateStatus(t *testing.T) {
+	a := assert.New(t)
+
+	repo := NewMemoryOrderRepository()
+	svc := NewOrderService(repo, distance.NewMockCalculator(0, nil), nil)
+
+	o, err := svc.CreateOrder(context.Background(), Endpoint{Coordinate: []string{"1", "2"}}, Endpoint{Coordinate: []string{"1.5", "1.6"}}, distance.Options{})
+	a.Nil(err, "order should be created without err")
+
+	for _, status := range []string{StatusTaken, StatusPickedUp, StatusDelivered} {
+		a.Nil(svc.UpdateStatus(context.Background(), o.ID, status, testCourierID), "order should move to %s", status)
+	}
+
+	delivered, _ := svc.GetOrder(context.Background(), o.ID)
+	a.Equal(StatusDelivered, delivered.Status, "order should be delivered")
+	a.NotNil(delivered.TakenAt, "taken time should be set")
+	a.NotNil(delivered.PickedUpAt, "pick up time should be set")
+	a.NotNil(delivered.DeliveredAt, "delivery time should be set")
+}
+
+// test for update status of an order taken by another courier
+func TestUpdateStatus_Not_Assignee(t *testing.T) {
+	a := assert.New(t)
+
+	repo := NewMemoryOrderRepository()
+	svc := NewOrderService(repo, distance.NewMockCalculator(0, nil), nil)
+
+	o, _ := svc.CreateOrder(context.Background(), Endpoint{Coordinate: []string{"1", "2"}}, Endpoint{Coordinate: []string{"1.5", "1.6"}}, distance.Options{})
+	a.Nil(svc.UpdateStatus(context.Background(), o.ID, StatusTaken, testCourierID), "order should be taken")
+
+	err := svc.UpdateStatus(context.Background(), o.ID, StatusPickedUp, "courier-2")
+	a.Equal(e.ErrOrderNotAssignee, err, "error should be not assignee")
+
+	stored, _ := svc.GetOrder(context.Background(), o.ID)
+	a.Equal(StatusTaken, stored.Status, "status should not change")
+}
+
+// test for update status back to unassigned release the order
+func TestUpdateStatus_Release(t *testing.T) {
+	a := assert.New(t)
+
+	repo := NewMemoryOrderRepository()
+	svc := NewOrderService(repo, distance.NewMockCalculator(0, nil), nil).WithReleaseCooldown(time.Hour)
+
+	o, _ := svc.CreateOrder(context.Background(), Endpoint{Coordinate: []string{"1", "2"}}, Endpoint{Coordinate: []string{"1.5", "1.6"}}, distance.Options{})
+	a.Nil(svc.UpdateStatus(context.Background(), o.ID, StatusTaken, testCourierID), "order should be taken")
+
+	err := svc.UpdateStatus(context.Background(), o.ID, StatusUnassigned, "courier-2")
+	a.Equal(e.ErrOrderNotAssignee, err, "only the assignee should release the order")
+
+	a.Nil(svc.UpdateStatus(context.Background(), o.ID, StatusUnassigned, testCourierID), "order should be released")
+
+	stored, _ := svc.GetOrder(context.Background(), o.ID)
+	a.Equal(StatusUnassigned, stored.Status, "status should be UNASSIGNED")
+	a.Empty(stored.AssigneeID, "assignee should be cleared")
+	a.Nil(stored.TakenAt, "taken time should be cleared")
+	a.Len(stored.Releases, 1, "release should be recorded")
+
+	err = svc.UpdateStatus(context.Background(), o.ID, StatusTaken, testCourierID)
+	a.Equal(e.ErrOrderReleaseCooldown, err, "same courier should wait for the cooldown")
+}
+
+// test for update status to a status which is not allowed from the current one
+func TestUpdateStatus_Illegal(t *testing.T) {
+	a := assert.New(t)
+
+	repo := NewMemoryOrderRepository()
+	svc := NewOrderService(repo, distance.NewMockCalculator(0, nil), nil)
+
+	o, _ := svc.CreateOrder(context.Background(), Endpoint{Coordinate: []string{"1", "2"}}, Endpoint{Coordinate: []string{"1.5", "1.6"}}, distance.Options{})
+
+	err := svc.UpdateStatus(context.Background(), o.ID, StatusDelivered, testCourierID)
+
+	a.Equal(&e.TransitionError{Current: StatusUnassigned, Requested: StatusDelivered}, err, "error should name both statuses")
+	a.Equal("the order can not move from UNASSIGNED to DELIVERED", err.Error(), "error should read the statuses")
+
+	stored, _ := svc.GetOrder(context.Background(), o.ID)
+	a.Equal(StatusUnassigned, stored.Status, "status should not change")
+}
+
+// test for update status of an order which does not exist
+func TestUpdateStatus_Not_Exist(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil)
+
+	err := svc.UpdateStatus(context.Background(), 1234, StatusCancelled, testCourierID)
+
+	a.Equal(e.ErrOrderNotExist, err, "error should be order not exist")
+}
+
+// test for cancel order in every status
+func TestCancelOrder(t *testing.T) {
+	a := assert.New(t)
+
+	cases := map[string]error{
+		StatusUnassigned: nil,
+		StatusTaken:      nil,
+		StatusPickedUp:   &e.TransitionError{Current: StatusPickedUp, Requested: StatusCancelled},
+		StatusDelivered:  e.ErrOrderAlreadyDelivered,
+		StatusCancelled:  e.ErrOrderAlreadyCancelled,
+		StatusFailed:     &e.TransitionError{Current: StatusFailed, Requested: StatusCancelled},
+	}
+
+	for status, expect := range cases {
+		repo := NewMemoryOrderRepository()
+		svc := NewOrderService(repo, distance.NewMockCalculator(0, nil), nil)
+
+		o := Order{Status: status}
+		if err := repo.Create(context.Background(), &o); err != nil {
+			t.Fatal(err)
+		}
+
+		c := Cancellation{By: "courier-7", Reason: CancelReasonOther, Note: "shop is closed"}
+		err := svc.CancelOrder(context.Background(), o.ID, c)
+		a.Equal(expect, err, "cancel of a %s order should match", status)
+
+		stored, _ := repo.Get(context.Background(), o.ID)
+		if expect == nil {
+			a.Equal(StatusCancelled, stored.Status, "%s order should be cancelled", status)
+			a.Equal("courier-7", stored.CancelledBy, "who cancelled should be stored")
+		} else {
+			a.Equal(status, stored.Status, "%s order should not change", status)
+			a.Empty(stored.CancelReason, "reason should not be stored")
+		}
+	}
+}
+
+// test for cancel order which does not exist
+func TestCancelOrder_Not_Exist(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil)
+
+	err := svc.CancelOrder(context.Background(), 1234, Cancellation{By: "courier-7", Reason: CancelReasonDuplicate})
+
+	a.Equal(e.ErrOrderNotExist, err, "error should be order not exist")
+}
+
+// test for the courier can not take again the order they have just released
+func TestUpdateStatus_Release_Cooldown(t *testing.T) {
+	a := assert.New(t)
+
+	repo := NewMemoryOrderRepository()
+	svc := NewOrderService(repo, distance.NewMockCalculator(0, nil), nil).WithReleaseCooldown(time.Hour)
+
+	o, _ := svc.CreateOrder(context.Background(), Endpoint{Coordinate: []string{"1", "2"}}, Endpoint{Coordinate: []string{"1.5", "1.6"}}, distance.Options{})
+
+	a.Nil(svc.UpdateStatus(context.Background(), o.ID, StatusTaken, testCourierID), "order should be taken")
+	a.Nil(svc.ReleaseOrder(context.Background(), o.ID, testCourierID), "order should be released")
+
+	err := svc.UpdateStatus(context.Background(), o.ID, StatusTaken, testCourierID)
+	a.Equal(e.ErrOrderReleaseCooldown, err, "same courier should wait for the cooldown")
+
+	err = svc.UpdateStatus(context.Background(), o.ID, StatusTaken, "courier-2")
+	a.Nil(err, "other courier should take the order")
+}
+
+// test for the courier can take again the order they released without the cooldown
+func TestUpdateStatus_Release_No_Cooldown(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewMemoryOrderRepository(), distance.NewMockCalculator(0, nil), nil)
+
+	o, _ := svc.CreateOrder(context.Background(), Endpoint{Coordinate: []string{"1", "2"}}, Endpoint{Coordinate: []string{"1.5", "1.6"}}, distance.Options{})
+
+	a.Nil(svc.UpdateStatus(context.Background(), o.ID, StatusTaken, testCourierID), "order should be taken")
+	a.Nil(svc.ReleaseOrder(context.Background(), o.ID, testCourierID), "order should be released")
+	a.Nil(svc.UpdateStatus(context.Background(), o.ID, StatusTaken, testCourierID), "order should be taken again")
+}
+
+// calculator which never answers until the caller gives up
+type hangingCalculator struct{}
+
+func (hangingCalculator) Calculate(ctx context.Context, src []string, des []string, opts distance.Options) (distance.Result, error) {
+	<-ctx.Done()
+	return distance.Result{}, ctx.Err()
+}
+
+// test for create order give up on the distance of all the legs together once the lookup timeout is hit
+func TestCreateOrder_Lookup_Timeout(t *testing.T) {
+	a := assert.New(t)
+
+	svc := NewOrderService(NewMemoryOrderRepository(), hangingCalculator{}, nil).WithLookupTimeout(10 * time.Millisecond)
+
+	start := time.Now()
+	stop := Endpoint{Coordinate: []string{"1.2", "1.8"}}
+	_, err := svc.CreateOrder(context.Background(), Endpoint{Coordinate: []string{"1", "2"}}, Endpoint{Coordinate: []string{"1.5", "1.6"}}, distance.Options{}, stop)
+
+	a.Equal(e.ErrTimeout, err, "error should be the timeout")
+	a.True(time.Since(start) < time.Second, "every leg should share the deadline")
 }
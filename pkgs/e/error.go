@@ -2,10 +2,22 @@ package e
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 )
 
+// Note: the statuses are only set for the orders which can not move to the requested status
 type ResponseError struct {
-	Error string `json:"error"`
+	Error           string       `json:"error"`
+	Fields          []FieldError `json:"fields,omitempty"`
+	CurrentStatus   string       `json:"current_status,omitempty"`
+	RequestedStatus string       `json:"requested_status,omitempty"`
+}
+
+// struct for a single field which failed the validation
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
 }
 
 var (
@@ -13,19 +25,69 @@ var (
 	ErrDistanceUnknown = errors.New("the distance between origin and destination is unknown")
 	// Error for an order already taken
 	ErrOrderAlreadyTaken = errors.New("the order is already taken")
+	// Error for releasing or moving on an order which another courier has taken
+	ErrOrderNotAssignee = errors.New("the order is taken by another courier")
+	// Error for a courier in the request body other than the courier of the header
+	ErrCourierMismatch = errors.New("the courier_id does not match the courier of the X-Courier-ID header")
+	// Error for taking an order the courier has released a moment ago
+	ErrOrderReleaseCooldown = errors.New("the order was released by the courier too recently, try again later")
+	// Error for cancelling an order which is already cancelled
+	ErrOrderAlreadyCancelled = errors.New("the order is already cancelled")
+	// Error for cancelling an order which is already delivered
+	ErrOrderAlreadyDelivered = errors.New("the order is already delivered")
+	// Error for a cursor which was not handed out by the service
+	ErrCursorInvalid = errors.New("the cursor is invalid")
 	// Error for query string invalid
 	ErrQueryStringInvalid = errors.New("the query strings provided are invalid")
 	// Error for order quest invalid
 	ErrOrderRequestInvalid = errors.New("the order request is invalid")
+	// Error for order request with fields failed validation
+	ErrOrderRequestValidation = errors.New("the order request has invalid fields")
 	// Error for trying to take order which does not exist
 	ErrOrderNotExist = errors.New("the order requested does not exist")
+	// Error when the distance provider can not route with the travel mode or options of the order
+	ErrRouteOptionsUnsupported = errors.New("the distance provider does not support the routing options")
+	// Error when the distance provider keeps failing or is known to be down
+	ErrDistanceUnavailable = errors.New("the distance provider is unavailable, try again later")
+	// Error when the geocoder can not find the address of the order
+	ErrAddressNotFound = errors.New("the address could not be found")
+	// Error for an order given with addresses while no geocoder is configured
+	ErrGeocodingDisabled = errors.New("the addresses are not supported, use the coordinates")
+	// Error when a call to a dependency did not finish in time
+	ErrTimeout = errors.New("the request timed out")
 	// Error for all internal error should not be exposed
 	// Note: it should add error id and check it in the logs
 	ErrInternalError = errors.New("the request failed by internal error")
 )
 
+// Error for an order which can not move from its status to the requested one
+type TransitionError struct {
+	Current   string
+	Requested string
+}
+
+func (t *TransitionError) Error() string {
+	if t.Current == t.Requested {
+		return fmt.Sprintf("the order is already %s", strings.ToLower(strings.Replace(t.Current, "_", " ", -1)))
+	}
+
+	return fmt.Sprintf("the order can not move from %s to %s", t.Current, t.Requested)
+}
+
 // function to create a response error
 func CreateErr(err error) *ResponseError {
 	res := &ResponseError{Error: err.Error()}
 	return res
 }
+
+// function to create a response error listing the invalid fields
+func CreateValidationErr(err error, fields []FieldError) *ResponseError {
+	res := &ResponseError{Error: err.Error(), Fields: fields}
+	return res
+}
+
+// function to create a response error naming the current and the requested status
+func CreateTransitionErr(err *TransitionError) *ResponseError {
+	res := &ResponseError{Error: err.Error(), CurrentStatus: err.Current, RequestedStatus: err.Requested}
+	return res
+}
@@ -1,12 +1,189 @@
 package distance
 
-var calc Calculator
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/config"
+	"time"
+)
+
+const (
+	ProviderGoogle    = "google"
+	ProviderHaversine = "haversine"
+	ProviderOSRM      = "osrm"
+)
+
+// travel modes of the lookup
+const (
+	ModeDriving   = "driving"
+	ModeWalking   = "walking"
+	ModeBicycling = "bicycling"
+	ModeTransit   = "transit"
+)
+
+// features the route can avoid
+const (
+	AvoidTolls    = "tolls"
+	AvoidHighways = "highways"
+	AvoidFerries  = "ferries"
+)
+
+// every travel mode and feature to avoid the calculators know
+var (
+	Modes  = []string{ModeDriving, ModeWalking, ModeBicycling, ModeTransit}
+	Avoids = []string{AvoidTolls, AvoidHighways, AvoidFerries}
+)
+
+// routing options of a lookup, the zero value is driving without restrictions
+type Options struct {
+	// travel mode, empty is driving
+	Mode string
+	// features the route should avoid
+	Avoid []string
+	// time of departure to consider the traffic, zero is none
+	DepartureTime time.Time
+}
+
+// return the travel mode, driving when it is not set
+func (o Options) TravelMode() string {
+	if o.Mode == "" {
+		return ModeDriving
+	}
+
+	return o.Mode
+}
+
+// error of a provider which can not calculate with the options
+type UnsupportedOptionsError struct {
+	Provider string
+	// option which is not supported like mode
+	Field  string
+	Reason string
+}
+
+func (u *UnsupportedOptionsError) Error() string {
+	return fmt.Sprintf("%s: %s", u.Field, u.Reason)
+}
+
+// create the error of an option the provider does not support
+func unsupported(provider string, field string, value interface{}) error {
+	reason := fmt.Sprintf("%v is not supported by the %s provider", value, provider)
+	return &UnsupportedOptionsError{Provider: provider, Field: field, Reason: reason}
+}
+
+// result of a lookup
+type Result struct {
+	// distance in meters
+	Distance int
+	// travel time of the route, 0 when the provider does not estimate it
+	Duration time.Duration
+	// name of the provider which calculated the distance
+	Provider string
+	// status of the route as the provider returned it, empty when it has none
+	Status string
+}
 
 type Calculator interface {
-	Calculate(src []string, des []string) (int, error)
+	Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error)
+}
+
+// create the calculator for the configured providers, every call reaches a provider
+// Note: with more than one provider they are tried in order until one of them answers
+func NewProvider(c *config.Configuration) (Calculator, error) {
+	providers := c.DistanceConfig.GetProviders()
+	if len(providers) == 0 {
+		return nil, errors.New("no distance provider is configured")
+	}
+
+	calcs := make([]Calculator, 0, len(providers))
+	for _, provider := range providers {
+		calc, err := newProvider(c, provider)
+		if err != nil {
+			return nil, err
+		}
+		calcs = append(calcs, calc)
+	}
+
+	if len(calcs) == 1 {
+		return calcs[0], nil
+	}
+
+	return NewChainCalculator(calcs...), nil
 }
 
-// getter for the calculator
-func GetCalculator() Calculator {
+// create the calculator of a single provider with its timeout, metrics and retries
+func newProvider(c *config.Configuration, provider string) (Calculator, error) {
+	var (
+		calc Calculator
+		err  error
+	)
+	switch provider {
+	case ProviderGoogle:
+		calc, err = NewGoogleMapCalculator(c.MapConfig.GetMapApiKey())
+	case ProviderHaversine:
+		calc = NewHaversineCalculator(c.DistanceConfig.GetRoadFactor())
+	case ProviderOSRM:
+		calc, err = NewOSRMCalculator(c.OSRMConfig.GetBaseURL(), c.OSRMConfig.GetProfile())
+	default:
+		err = fmt.Errorf("unknown distance provider %q", provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout := c.DistanceConfig.GetTimeout(); timeout > 0 {
+		calc = NewTimeoutCalculator(calc, timeout)
+	}
+
+	calc = NewInstrumentedCalculator(calc, provider)
+
+	// only the remote providers have transient errors worth retrying
+	if provider != ProviderHaversine {
+		opts := ResilienceOptions{
+			MaxRetries:       c.DistanceConfig.GetMaxRetries(),
+			BaseBackoff:      c.DistanceConfig.GetRetryBackoff(),
+			MaxBackoff:       c.DistanceConfig.GetRetryMaxBackoff(),
+			FailureThreshold: c.DistanceConfig.GetBreakerThreshold(),
+			OpenTimeout:      c.DistanceConfig.GetBreakerOpenTimeout(),
+		}
+		calc = NewResilientCalculator(calc, provider, opts)
+	}
+
+	return calc, nil
+}
+
+// put the configured cache in front of the provider
+func WithCache(c *config.Configuration, calc Calculator) Calculator {
+	if size := c.DistanceConfig.GetCacheSize(); size > 0 {
+		opts := CacheOptions{
+			Precision:   c.DistanceConfig.GetCachePrecision(),
+			TTL:         c.DistanceConfig.GetCacheTTL(),
+			NegativeTTL: c.DistanceConfig.GetCacheNegativeTTL(),
+		}
+		// the first provider of the chain is the primary
+		if providers := c.DistanceConfig.GetProviders(); len(providers) > 0 {
+			opts.Primary = providers[0]
+			opts.Fallback = len(providers) > 1
+		}
+		return NewCachedCalculator(calc, NewLRUStore(size), opts)
+	}
+
 	return calc
 }
+
+// find the circuit breakers of the provider and of the calculators it chains
+func Circuits(calc Calculator) []*ResilientCalculator {
+	switch c := calc.(type) {
+	case *ResilientCalculator:
+		return []*ResilientCalculator{c}
+	case *ChainCalculator:
+		var circuits []*ResilientCalculator
+		for _, link := range c.Calculators() {
+			circuits = append(circuits, Circuits(link)...)
+		}
+		return circuits
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,220 @@
+package distance
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"order-service/pkgs/e"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "order_service",
+	Name:      "distance_cache_requests_total",
+	Help:      "Distance cache lookups by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+}
+
+// a cached lookup, unknown is set when the provider knows no route
+type CacheEntry struct {
+	Result
+	Unknown bool
+}
+
+// storage for the cached lookups, it can be in-process or shared by the instances
+type CacheStore interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, entry CacheEntry, ttl time.Duration)
+}
+
+// options of the caching calculator
+type CacheOptions struct {
+	// decimals kept on the coordinates, 4 is about 11 meters
+	Precision int
+	// how long a distance is cached
+	TTL time.Duration
+	// how long an unknown distance is cached
+	NegativeTTL time.Duration
+	// provider whose distances are cached, the ones of a fallback are not so the primary is asked again
+	// once it is back, empty caches every provider
+	Primary string
+	// the calculator falls back to other providers, an unknown distance does not tell which one answered
+	// so it is not cached
+	Fallback bool
+}
+
+// hit and miss counts of the cache
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// decorator caching the distance by the normalized origin and destination
+type CachedCalculator struct {
+	calc   Calculator
+	store  CacheStore
+	opts   CacheOptions
+	hits   uint64
+	misses uint64
+}
+
+// wrap the calculator with a cache on the store
+func NewCachedCalculator(calc Calculator, store CacheStore, opts CacheOptions) *CachedCalculator {
+	return &CachedCalculator{calc: calc, store: store, opts: opts}
+}
+
+// calculate with the cache first, then the wrapped calculator
+func (c *CachedCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	key, ok := c.key(src, des, opts)
+
+	// anything other than coordinates is not normalized so it is not cached,
+	// neither is a departure time since the traffic keeps changing
+	if !ok || !opts.DepartureTime.IsZero() {
+		return c.calc.Calculate(ctx, src, des, opts)
+	}
+
+	if entry, found := c.store.Get(key); found {
+		atomic.AddUint64(&c.hits, 1)
+		cacheRequests.WithLabelValues("hit").Inc()
+		if entry.Unknown {
+			return Result{}, e.ErrDistanceUnknown
+		}
+		return entry.Result, nil
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+	cacheRequests.WithLabelValues("miss").Inc()
+
+	d, err := c.calc.Calculate(ctx, src, des, opts)
+	switch {
+	case err == nil && c.opts.Primary != "" && d.Provider != c.opts.Primary:
+		// the fallback answered while the primary is down
+	case err == nil:
+		c.store.Set(key, CacheEntry{Result: d}, c.opts.TTL)
+	case err == e.ErrDistanceUnknown && !c.opts.Fallback:
+		c.store.Set(key, CacheEntry{Unknown: true}, c.opts.NegativeTTL)
+	}
+
+	return d, err
+}
+
+// return the hit and miss counts since the calculator is created
+func (c *CachedCalculator) Stats() CacheStats {
+	return CacheStats{Hits: atomic.LoadUint64(&c.hits), Misses: atomic.LoadUint64(&c.misses)}
+}
+
+// create the cache key with the coordinates rounded to the precision and the routing options
+func (c *CachedCalculator) key(src []string, des []string, opts Options) (string, bool) {
+	srcKey, ok := c.normalize(src)
+	if !ok {
+		return "", false
+	}
+
+	desKey, ok := c.normalize(des)
+	if !ok {
+		return "", false
+	}
+
+	// the same features to avoid in any order is the same route
+	avoid := append([]string(nil), opts.Avoid...)
+	sort.Strings(avoid)
+
+	return srcKey + "|" + desKey + "|" + opts.TravelMode() + "|" + strings.Join(avoid, ","), true
+}
+
+// round the [lat, lng] pair to the precision
+func (c *CachedCalculator) normalize(coordinate []string) (string, bool) {
+	if len(coordinate) != 2 {
+		return "", false
+	}
+
+	lat, err := strconv.ParseFloat(coordinate[0], 64)
+	if err != nil {
+		return "", false
+	}
+
+	lng, err := strconv.ParseFloat(coordinate[1], 64)
+	if err != nil {
+		return "", false
+	}
+
+	return fmt.Sprintf("%.*f,%.*f", c.opts.Precision, lat, c.opts.Precision, lng), true
+}
+
+// in-process store which keeps the most recently used entries up to the size
+type lruStore struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+type lruItem struct {
+	key       string
+	entry     CacheEntry
+	expiresAt time.Time
+}
+
+// create the in-process store holding up to size entries
+func NewLRUStore(size int) CacheStore {
+	return &lruStore{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get the entry which is not expired and mark it as recently used
+func (s *lruStore) Get(key string) (CacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+
+	item := el.Value.(*lruItem)
+	if !s.now().Before(item.expiresAt) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return CacheEntry{}, false
+	}
+
+	s.order.MoveToFront(el)
+	return item.entry, true
+}
+
+// set the entry and evict the least recently used one when full
+func (s *lruStore) Set(key string, entry CacheEntry, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := s.now().Add(ttl)
+	if el, ok := s.entries[key]; ok {
+		item := el.Value.(*lruItem)
+		item.entry = entry
+		item.expiresAt = expiresAt
+		s.order.MoveToFront(el)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&lruItem{key: key, entry: entry, expiresAt: expiresAt})
+
+	if s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruItem).key)
+	}
+}
@@ -0,0 +1,240 @@
+package distance
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"order-service/pkgs/e"
+	"testing"
+	"time"
+)
+
+// calculator counting the calls which reach it
+type countingCalculator struct {
+	calls    int
+	distance int
+	err      error
+}
+
+func (c *countingCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	c.calls++
+	return Result{Distance: c.distance, Provider: "counting"}, c.err
+}
+
+var testCacheOptions = CacheOptions{Precision: 4, TTL: time.Hour, NegativeTTL: time.Minute}
+
+// test for the same route is only calculated once
+func TestCachedCalculator(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &countingCalculator{distance: 1234}
+	c := NewCachedCalculator(inner, NewLRUStore(10), testCacheOptions)
+
+	for i := 0; i < 3; i++ {
+		d, err := c.Calculate(context.Background(), []string{"35.9984617", "-115.1432558"}, []string{"36.0222811", "-115.0980736"}, Options{})
+		a.Nil(err, "distance should be calculated without err")
+		a.Equal(1234, d.Distance, "distance should come from the wrapped calculator")
+		a.Equal("counting", d.Provider, "provider should be kept on the cached result")
+	}
+
+	a.Equal(1, inner.calls, "wrapped calculator should be called once")
+	a.Equal(CacheStats{Hits: 2, Misses: 1}, c.Stats(), "stats should count the hits and misses")
+}
+
+// test for the distances of a fallback provider are not cached
+func TestCachedCalculator_Fallback(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &countingCalculator{distance: 1234}
+	opts := testCacheOptions
+	opts.Primary = ProviderGoogle
+	c := NewCachedCalculator(inner, NewLRUStore(10), opts)
+
+	for i := 0; i < 2; i++ {
+		d, err := c.Calculate(context.Background(), []string{"35.9984617", "-115.1432558"}, []string{"36.0222811", "-115.0980736"}, Options{})
+		a.Nil(err, "distance should be calculated without err")
+		a.Equal(1234, d.Distance, "distance should come from the wrapped calculator")
+	}
+
+	a.Equal(2, inner.calls, "distance of the fallback should be calculated every time")
+}
+
+// test for the unknown distance is not cached when a fallback may have answered it
+func TestCachedCalculator_Fallback_Negative(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &countingCalculator{err: e.ErrDistanceUnknown}
+	opts := testCacheOptions
+	opts.Primary = ProviderGoogle
+	opts.Fallback = true
+	c := NewCachedCalculator(inner, NewLRUStore(10), opts)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Calculate(context.Background(), []string{"35.9984617", "-115.1432558"}, []string{"36.0222811", "-115.0980736"}, Options{})
+		a.Equal(e.ErrDistanceUnknown, err, "error should be distance unknown")
+	}
+
+	a.Equal(2, inner.calls, "unknown distance should be calculated every time")
+}
+
+// test for the coordinates are rounded to the precision for the key
+func TestCachedCalculator_Normalize(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &countingCalculator{distance: 1234}
+	c := NewCachedCalculator(inner, NewLRUStore(10), testCacheOptions)
+
+	c.Calculate(context.Background(), []string{"35.99846", "-115.14321"}, []string{"36.02228", "-115.09807"}, Options{})
+	c.Calculate(context.Background(), []string{"35.998461", "-115.143214"}, []string{"36.022281", "-115.098073"}, Options{})
+	a.Equal(1, inner.calls, "routes within the precision should share the entry")
+
+	c.Calculate(context.Background(), []string{"35.9994", "-115.1432"}, []string{"36.0222", "-115.0980"}, Options{})
+	a.Equal(2, inner.calls, "routes beyond the precision should not share the entry")
+}
+
+// test for the unknown distance is cached for the shorter ttl
+func TestCachedCalculator_Negative(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	now := time.Now()
+	store := NewLRUStore(10).(*lruStore)
+	store.now = func() time.Time { return now }
+
+	inner := &countingCalculator{err: e.ErrDistanceUnknown}
+	c := NewCachedCalculator(inner, store, testCacheOptions)
+
+	src, des := []string{"1", "2"}, []string{"1.5", "1.6"}
+	for i := 0; i < 2; i++ {
+		_, err := c.Calculate(context.Background(), src, des, Options{})
+		a.Equal(e.ErrDistanceUnknown, err, "unknown distance should be returned")
+	}
+	a.Equal(1, inner.calls, "unknown distance should be cached")
+
+	// after the negative ttl the provider is asked again
+	now = now.Add(testCacheOptions.NegativeTTL)
+	c.Calculate(context.Background(), src, des, Options{})
+	a.Equal(2, inner.calls, "unknown distance should expire after the negative ttl")
+}
+
+// test for the distance expire after the ttl
+func TestCachedCalculator_Expire(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	now := time.Now()
+	store := NewLRUStore(10).(*lruStore)
+	store.now = func() time.Time { return now }
+
+	inner := &countingCalculator{distance: 1234}
+	c := NewCachedCalculator(inner, store, testCacheOptions)
+
+	src, des := []string{"1", "2"}, []string{"1.5", "1.6"}
+	c.Calculate(context.Background(), src, des, Options{})
+
+	now = now.Add(testCacheOptions.NegativeTTL)
+	c.Calculate(context.Background(), src, des, Options{})
+	a.Equal(1, inner.calls, "distance should still be cached after the negative ttl")
+
+	now = now.Add(testCacheOptions.TTL)
+	c.Calculate(context.Background(), src, des, Options{})
+	a.Equal(2, inner.calls, "distance should expire after the ttl")
+}
+
+// test for the other errors are not cached
+func TestCachedCalculator_Error(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &countingCalculator{err: errors.New("test for service exception")}
+	c := NewCachedCalculator(inner, NewLRUStore(10), testCacheOptions)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+		a.Equal(inner.err, err, "error should come from the wrapped calculator")
+	}
+	a.Equal(2, inner.calls, "error should not be cached")
+}
+
+// test for the input which is not a coordinate skip the cache
+func TestCachedCalculator_Not_Coordinate(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &countingCalculator{distance: 1234}
+	c := NewCachedCalculator(inner, NewLRUStore(10), testCacheOptions)
+
+	for i := 0; i < 2; i++ {
+		c.Calculate(context.Background(), []string{"Las Vegas", "NV"}, []string{"1.5", "1.6"}, Options{})
+	}
+	a.Equal(2, inner.calls, "address should not be cached")
+	a.Equal(CacheStats{}, c.Stats(), "address should not count as hit or miss")
+}
+
+// test for the least recently used entry is evicted when full
+func TestLRUStore_Evict(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	s := NewLRUStore(2)
+	s.Set("a", CacheEntry{Result: Result{Distance: 1}}, time.Hour)
+	s.Set("b", CacheEntry{Result: Result{Distance: 2}}, time.Hour)
+
+	// use a so b is the least recently used
+	_, ok := s.Get("a")
+	a.True(ok, "a should be cached")
+
+	s.Set("c", CacheEntry{Result: Result{Distance: 3}}, time.Hour)
+
+	_, ok = s.Get("b")
+	a.False(ok, "b should be evicted")
+
+	entry, ok := s.Get("a")
+	a.True(ok, "a should be kept")
+	a.Equal(1, entry.Distance, "a should keep its distance")
+
+	entry, ok = s.Get("c")
+	a.True(ok, "c should be cached")
+	a.Equal(3, entry.Distance, "c should keep its distance")
+}
+
+// test for the routing options are part of the key
+func TestCachedCalculator_Options(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &countingCalculator{distance: 1234}
+	c := NewCachedCalculator(inner, NewLRUStore(10), testCacheOptions)
+
+	src, des := []string{"1", "2"}, []string{"1.5", "1.6"}
+	c.Calculate(context.Background(), src, des, Options{})
+	c.Calculate(context.Background(), src, des, Options{Mode: ModeDriving})
+	a.Equal(1, inner.calls, "empty mode should share the entry of driving")
+
+	c.Calculate(context.Background(), src, des, Options{Mode: ModeWalking})
+	a.Equal(2, inner.calls, "other mode should not share the entry")
+
+	c.Calculate(context.Background(), src, des, Options{Avoid: []string{AvoidTolls, AvoidFerries}})
+	c.Calculate(context.Background(), src, des, Options{Avoid: []string{AvoidFerries, AvoidTolls}})
+	a.Equal(3, inner.calls, "features to avoid in any order should share the entry")
+
+	// the traffic keeps changing
+	departure := Options{DepartureTime: time.Now()}
+	c.Calculate(context.Background(), src, des, departure)
+	c.Calculate(context.Background(), src, des, departure)
+	a.Equal(5, inner.calls, "departure time should not be cached")
+}
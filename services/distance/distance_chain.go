@@ -0,0 +1,63 @@
+package distance
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"order-service/pkgs/e"
+)
+
+// composite trying the calculators in order until one of them answers
+type ChainCalculator struct {
+	calcs []Calculator
+}
+
+// create the chain of the calculators, the first one is the primary
+func NewChainCalculator(calcs ...Calculator) *ChainCalculator {
+	return &ChainCalculator{calcs: calcs}
+}
+
+// calculate with the first calculator which answers, the result tells which one it was
+// Note: an unknown route is a definitive answer so the next ones are not tried, the calculators which can not
+// calculate with the options are skipped and their error is only returned when none of the others could try
+func (c *ChainCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	var failed, unsupported error
+	for i, calc := range c.calcs {
+		res, err := calc.Calculate(ctx, src, des, opts)
+		if err == nil || err == e.ErrDistanceUnknown {
+			return res, err
+		}
+
+		// the caller gave up, no other calculator can help
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+
+		if _, ok := err.(*UnsupportedOptionsError); ok {
+			if unsupported == nil {
+				unsupported = err
+			}
+			logrus.Debugf("distance calculator %d of the chain does not support the options, skip it: %v", i+1, err)
+			continue
+		}
+
+		failed = err
+		if i < len(c.calcs)-1 {
+			logrus.Warnf("distance calculator %d of the chain failed, fall back to the next one: %v", i+1, err)
+		}
+	}
+
+	switch {
+	case failed != nil:
+		return Result{}, failed
+	case unsupported != nil:
+		return Result{}, unsupported
+	default:
+		// an empty chain has no calculator to answer
+		return Result{}, e.ErrDistanceUnavailable
+	}
+}
+
+// return the calculators of the chain in order
+func (c *ChainCalculator) Calculators() []Calculator {
+	return c.calcs
+}
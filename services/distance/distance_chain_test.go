@@ -0,0 +1,142 @@
+package distance
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"order-service/pkgs/e"
+	"testing"
+	"time"
+)
+
+// test for the primary answer the lookup without the fallbacks
+func TestChainCalculator(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	fallback := &countingCalculator{distance: 2000}
+	c := NewChainCalculator(NewMockCalculator(1000, nil), fallback)
+
+	res, err := c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal(Result{Distance: 1000, Provider: ProviderMock}, res, "result should come from the primary")
+	a.Equal(0, fallback.calls, "fallback should not be called")
+}
+
+// test for the next calculator answer when the primary fails
+func TestChainCalculator_Fallback(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	primary := NewMockCalculator(0, e.ErrDistanceUnavailable)
+	second := NewMockCalculator(0, errors.New("test for service exception"))
+	c := NewChainCalculator(primary, second, NewHaversineCalculator(1))
+
+	res, err := c.Calculate(context.Background(), []string{"0", "0"}, []string{"0", "1"}, Options{})
+
+	a.Nil(err, "distance should be calculated by the fallback")
+	a.Equal(ProviderHaversine, res.Provider, "result should tell the fallback calculated it")
+	a.True(res.Distance > 0, "distance should come from the fallback")
+}
+
+// test for the unknown route stop the chain
+func TestChainCalculator_Unknown(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	fallback := &countingCalculator{distance: 2000}
+	c := NewChainCalculator(NewMockCalculator(0, e.ErrDistanceUnknown), fallback)
+
+	_, err := c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+
+	a.Equal(e.ErrDistanceUnknown, err, "error should be distance unknown")
+	a.Equal(0, fallback.calls, "fallback should not be called for a definitive answer")
+}
+
+// test for the error of the last calculator when every one fails
+func TestChainCalculator_All_Failed(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	last := errors.New("test for service exception")
+	c := NewChainCalculator(NewMockCalculator(0, e.ErrDistanceUnavailable), NewMockCalculator(0, last))
+
+	_, err := c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+
+	a.Equal(last, err, "error should come from the last calculator")
+}
+
+// test for the outage of the primary kept when the fallback does not support the options
+func TestChainCalculator_Unsupported_Fallback(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	c := NewChainCalculator(NewMockCalculator(0, e.ErrDistanceUnavailable), NewHaversineCalculator(1))
+	opts := Options{DepartureTime: time.Now().Add(time.Hour)}
+
+	_, err := c.Calculate(context.Background(), []string{"0", "0"}, []string{"0", "1"}, opts)
+
+	a.Equal(e.ErrDistanceUnavailable, err, "error should be the outage of the primary")
+}
+
+// test for the calculators which do not support the options skipped
+func TestChainCalculator_Unsupported_Skipped(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	opts := Options{Mode: ModeTransit}
+	c := NewChainCalculator(NewHaversineCalculator(1), NewMockCalculator(1000, nil))
+
+	res, err := c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, opts)
+	a.Nil(err, "distance should be calculated by the calculator which supports the options")
+	a.Equal(ProviderMock, res.Provider, "result should come from the calculator which supports the options")
+
+	// no calculator supports the options
+	c = NewChainCalculator(NewHaversineCalculator(1), NewHaversineCalculator(1))
+
+	_, err = c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, opts)
+	uerr, ok := err.(*UnsupportedOptionsError)
+	if a.True(ok, "error should be unsupported options") {
+		a.Equal("mode", uerr.Field, "error should name the option")
+	}
+}
+
+// test for the chain stop once the caller gives up
+func TestChainCalculator_Canceled(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	fallback := &countingCalculator{distance: 2000}
+	c := NewChainCalculator(blockingCalculator{}, fallback)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.Calculate(ctx, []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+
+	a.Equal(context.Canceled, err, "error should be canceled")
+	a.Equal(0, fallback.calls, "fallback should not be called")
+}
+
+// test for the circuit breakers found in the chain
+func TestCircuits(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	google := NewResilientCalculator(NewMockCalculator(100, nil), ProviderGoogle, testResilienceOptions)
+	osrm := NewResilientCalculator(NewMockCalculator(100, nil), "osrm", testResilienceOptions)
+	chain := NewChainCalculator(google, osrm, NewHaversineCalculator(1))
+
+	a.Equal([]*ResilientCalculator{google, osrm}, Circuits(chain), "every circuit of the chain should be found")
+	a.Equal([]*ResilientCalculator{google}, Circuits(google), "circuit of the provider should be found")
+	a.Empty(Circuits(NewHaversineCalculator(1)), "calculator without circuit should have none")
+}
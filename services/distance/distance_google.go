@@ -2,46 +2,69 @@ package distance
 
 import (
 	"context"
-	"github.com/sirupsen/logrus"
+	"fmt"
 	"googlemaps.github.io/maps"
-	"order-service/config"
+	"net/http"
+	"net/url"
 	"order-service/pkgs/e"
+	"strconv"
 	"strings"
 )
 
+// statuses of google which may go away on the next call
+var googleRetryableStatuses = []string{"OVER_QUERY_LIMIT", "UNKNOWN_ERROR"}
+
 type googleMapCalculator struct {
 	client *maps.Client
 }
 
-// initialize the google map client
-func InitGoogleMapCalculator() {
-	apiKey := config.GetConfig().MapConfig.GetMapApiKey()
+// create the calculator with a google map client
+func NewGoogleMapCalculator(apiKey string) (Calculator, error) {
+	return newGoogleMapCalculator(maps.WithAPIKey(apiKey))
+}
+
+// create the calculator with the options of the google map client
+func newGoogleMapCalculator(options ...maps.ClientOption) (Calculator, error) {
+	// the server errors are turned into errors of the request so they can be retried
+	httpClient := &http.Client{Transport: serverErrorTransport{next: http.DefaultTransport}}
 
 	// init google map api client
-	c, err := maps.NewClient(maps.WithAPIKey(apiKey))
+	c, err := maps.NewClient(append([]maps.ClientOption{maps.WithHTTPClient(httpClient)}, options...)...)
 	if err != nil {
-		logrus.Fatal(err)
+		return nil, err
 	}
 
 	var googleCalc googleMapCalculator
 	googleCalc.client = c
 
-	calc = &googleCalc
+	return &googleCalc, nil
 }
 
 // calculate the distance between
-func (c *googleMapCalculator) Calculate(src []string, des []string) (int, error) {
+func (c *googleMapCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
 	srcStr := strings.Join(src, ",")
 	desStr := strings.Join(des, ",")
 
+	// the transit routes follow the timetable so they can not avoid anything
+	if opts.TravelMode() == ModeTransit && len(opts.Avoid) > 0 {
+		return Result{}, unsupported(ProviderGoogle, "avoid", "avoid with transit mode")
+	}
+
 	req := new(maps.DistanceMatrixRequest)
 	req.Origins = append(req.Origins, srcStr)
 	req.Destinations = append(req.Destinations, desStr)
+	req.Mode = maps.Mode(opts.TravelMode())
+	if len(opts.Avoid) > 0 {
+		req.Avoid = maps.Avoid(strings.Join(opts.Avoid, "|"))
+	}
+	if !opts.DepartureTime.IsZero() {
+		req.DepartureTime = strconv.FormatInt(opts.DepartureTime.Unix(), 10)
+	}
 
 	// use the distance matrix api
-	res, err := c.client.DistanceMatrix(context.Background(), req)
+	res, err := c.client.DistanceMatrix(ctx, req)
 	if err != nil {
-		return 0, err
+		return Result{}, googleErr(err)
 	}
 
 	// use the first result since there is no specific info provided
@@ -49,11 +72,54 @@ func (c *googleMapCalculator) Calculate(src []string, des []string) (int, error)
 
 	// if google can't find a route
 	if el.Status != "OK" {
-		return 0, e.ErrDistanceUnknown
+		return Result{}, e.ErrDistanceUnknown
 	}
 
 	// use the the first result
-	result := el.Distance.Meters
+	return Result{
+		Distance: el.Distance.Meters,
+		Duration: el.Duration,
+		Provider: ProviderGoogle,
+		Status:   el.Status,
+	}, nil
+}
+
+// function to mark the errors of google which may go away on the next call
+func googleErr(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		if _, ok := urlErr.Err.(*retryableError); ok {
+			return urlErr.Err
+		}
+		if urlErr.Timeout() {
+			return retryable(err)
+		}
+		return err
+	}
+
+	for _, status := range googleRetryableStatuses {
+		if strings.Contains(err.Error(), status) {
+			return retryable(err)
+		}
+	}
+
+	return err
+}
+
+// transport failing the requests which google answered with a server error
+type serverErrorTransport struct {
+	next http.RoundTripper
+}
+
+func (t serverErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		res.Body.Close()
+		return nil, retryable(fmt.Errorf("google map responded with %s", res.Status))
+	}
 
-	return result, nil
+	return res, nil
 }
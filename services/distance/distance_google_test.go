@@ -0,0 +1,165 @@
+package distance
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"googlemaps.github.io/maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"order-service/pkgs/e"
+	"testing"
+	"time"
+)
+
+// distance matrix response with a single element
+const googleOKResponse = `{
+  "status": "OK",
+  "origin_addresses": ["Las Vegas, NV, USA"],
+  "destination_addresses": ["Henderson, NV, USA"],
+  "rows": [{"elements": [{"status": "OK", "distance": {"text": "6.4 km", "value": 6412}, "duration": {"text": "9 mins", "value": 540}}]}]
+}`
+
+// helper function to create the calculator against a stand-in of the google api
+// Note: the server has to be closed by the caller
+func newTestGoogleCalculator(t *testing.T, status int, body string, queries ...*url.Values) (Calculator, *httptest.Server) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, q := range queries {
+			*q = r.URL.Query()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+
+	calc, err := newGoogleMapCalculator(maps.WithAPIKey("test-key"), maps.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return calc, srv
+}
+
+// helper function to run a lookup on the test route
+func calculateGoogleRoute(c Calculator) (Result, error) {
+	return c.Calculate(context.Background(), []string{"36.1146", "-115.1728"}, []string{"36.0395", "-114.9817"}, Options{})
+}
+
+// test for the distance parsed from the distance matrix
+func TestGoogleMapCalculator(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	calc, srv := newTestGoogleCalculator(t, http.StatusOK, googleOKResponse)
+	defer srv.Close()
+
+	d, err := calculateGoogleRoute(calc)
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal(6412, d.Distance, "distance should come from the element")
+	a.Equal(540*time.Second, d.Duration, "duration should come from the element")
+	a.Equal(ProviderGoogle, d.Provider, "provider should be google")
+	a.Equal("OK", d.Status, "status should be the status of the element")
+}
+
+// test for the route google does not know
+func TestGoogleMapCalculator_Unknown(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	body := `{"status": "OK", "rows": [{"elements": [{"status": "ZERO_RESULTS"}]}]}`
+	calc, srv := newTestGoogleCalculator(t, http.StatusOK, body)
+	defer srv.Close()
+
+	_, err := calculateGoogleRoute(calc)
+
+	a.Equal(e.ErrDistanceUnknown, err, "error should be distance unknown")
+}
+
+// test for the errors of google marked as retryable or not
+func TestGoogleMapCalculator_Errors(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	cases := []struct {
+		status    int
+		body      string
+		retryable bool
+	}{
+		{http.StatusOK, `{"status": "OVER_QUERY_LIMIT", "error_message": "You have exceeded your rate-limit."}`, true},
+		{http.StatusOK, `{"status": "UNKNOWN_ERROR"}`, true},
+		{http.StatusBadGateway, `<html>Bad Gateway</html>`, true},
+		{http.StatusServiceUnavailable, `{"status": "UNKNOWN_ERROR"}`, true},
+		{http.StatusOK, `{"status": "REQUEST_DENIED", "error_message": "The provided API key is invalid."}`, false},
+		{http.StatusOK, `{"status": "INVALID_REQUEST"}`, false},
+	}
+
+	for _, tc := range cases {
+		calc, srv := newTestGoogleCalculator(t, tc.status, tc.body)
+		_, err := calculateGoogleRoute(calc)
+		srv.Close()
+
+		a.NotNil(err, "error should be returned for %d %s", tc.status, tc.body)
+		a.Equal(tc.retryable, isRetryable(err), "retryable should match for %d %s", tc.status, tc.body)
+	}
+}
+
+// test for the routing options sent to google
+func TestGoogleMapCalculator_Options(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	var query url.Values
+	calc, srv := newTestGoogleCalculator(t, http.StatusOK, googleOKResponse, &query)
+	defer srv.Close()
+
+	departure := time.Date(2030, 1, 2, 8, 0, 0, 0, time.UTC)
+	opts := Options{Mode: ModeBicycling, Avoid: []string{AvoidTolls, AvoidFerries}, DepartureTime: departure}
+	_, err := calc.Calculate(context.Background(), []string{"36.1146", "-115.1728"}, []string{"36.0395", "-114.9817"}, opts)
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal("bicycling", query.Get("mode"), "mode should be sent")
+	a.Equal("tolls|ferries", query.Get("avoid"), "features to avoid should be sent")
+	a.Equal("1893571200", query.Get("departure_time"), "departure time should be sent in unix seconds")
+}
+
+// test for the default mode sent to google
+func TestGoogleMapCalculator_Default_Mode(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	var query url.Values
+	calc, srv := newTestGoogleCalculator(t, http.StatusOK, googleOKResponse, &query)
+	defer srv.Close()
+
+	_, err := calculateGoogleRoute(calc)
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal("driving", query.Get("mode"), "mode should be driving")
+	a.Empty(query.Get("avoid"), "nothing should be avoided")
+	a.Empty(query.Get("departure_time"), "departure time should not be sent")
+}
+
+// test for google rejecting the features to avoid on transit
+func TestGoogleMapCalculator_Transit_Avoid(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	var query url.Values
+	calc, srv := newTestGoogleCalculator(t, http.StatusOK, googleOKResponse, &query)
+	defer srv.Close()
+
+	opts := Options{Mode: ModeTransit, Avoid: []string{AvoidTolls}}
+	_, err := calc.Calculate(context.Background(), []string{"36.1146", "-115.1728"}, []string{"36.0395", "-114.9817"}, opts)
+
+	if a.IsType(&UnsupportedOptionsError{}, err, "error should be unsupported options") {
+		a.Equal("avoid", err.(*UnsupportedOptionsError).Field, "avoid should be rejected")
+	}
+	a.Nil(query, "google should not be called")
+}
@@ -0,0 +1,95 @@
+package distance
+
+import (
+	"context"
+	"math"
+	"order-service/pkgs/e"
+	"strconv"
+	"strings"
+)
+
+// mean radius of the earth in meters
+const earthRadius = 6371008.8
+
+type haversineCalculator struct {
+	roadFactor float64
+}
+
+// create the offline great-circle calculator
+func NewHaversineCalculator(roadFactor float64) Calculator {
+	var haversineCalc haversineCalculator
+	haversineCalc.roadFactor = roadFactor
+
+	// a factor below 1 would make it shorter than the straight line
+	if haversineCalc.roadFactor < 1 {
+		haversineCalc.roadFactor = 1
+	}
+
+	return &haversineCalc
+}
+
+// calculate the straight-line distance between, adjusted by the road factor
+// Note: the straight line is the same for every mode which is not bound to a timetable
+func (c *haversineCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	// the options which change the route can not be honored
+	if opts.TravelMode() == ModeTransit {
+		return Result{}, unsupported(ProviderHaversine, "mode", opts.Mode)
+	}
+	if len(opts.Avoid) > 0 {
+		return Result{}, unsupported(ProviderHaversine, "avoid", strings.Join(opts.Avoid, ","))
+	}
+	if !opts.DepartureTime.IsZero() {
+		return Result{}, unsupported(ProviderHaversine, "departure_time", "departure time")
+	}
+
+	srcLat, srcLng, err := parseCoordinate(src)
+	if err != nil {
+		return Result{}, err
+	}
+
+	desLat, desLng, err := parseCoordinate(des)
+	if err != nil {
+		return Result{}, err
+	}
+
+	d := haversine(srcLat, srcLng, desLat, desLng) * c.roadFactor
+
+	return Result{Distance: int(math.Round(d)), Provider: ProviderHaversine}, nil
+}
+
+// parse the [lat, lng] pair, anything else can't be measured without a geocoder
+func parseCoordinate(c []string) (float64, float64, error) {
+	if len(c) != 2 {
+		return 0, 0, e.ErrDistanceUnknown
+	}
+
+	lat, err := strconv.ParseFloat(c[0], 64)
+	if err != nil || !isFinite(lat) || lat < -90 || lat > 90 {
+		return 0, 0, e.ErrDistanceUnknown
+	}
+
+	lng, err := strconv.ParseFloat(c[1], 64)
+	if err != nil || !isFinite(lng) || lng < -180 || lng > 180 {
+		return 0, 0, e.ErrDistanceUnknown
+	}
+
+	return lat, lng, nil
+}
+
+// check the value is a number, NaN passes every comparison of the range
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// great-circle distance in meters between two coordinates in degrees
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lng2 - lng1) * math.Pi / 180
+
+	h := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
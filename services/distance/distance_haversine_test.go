@@ -0,0 +1,117 @@
+package distance
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"order-service/pkgs/e"
+	"testing"
+	"time"
+)
+
+// test for the straight-line distance between two known cities
+func TestHaversineCalculator(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c := haversineCalculator{roadFactor: 1}
+
+	// london to paris is about 343.5 km
+	d, err := c.Calculate(context.Background(), []string{"51.5074", "-0.1278"}, []string{"48.8566", "2.3522"}, Options{})
+
+	a.Nil(err, "distance should be calculated without err")
+	a.InDelta(343500, d.Distance, 1000, "distance should be about 343.5 km")
+}
+
+// test for the distance half way around the earth
+func TestHaversineCalculator_Antipode(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c := haversineCalculator{roadFactor: 1}
+
+	d, err := c.Calculate(context.Background(), []string{"0", "0"}, []string{"0", "180"}, Options{})
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal(int(math.Round(math.Pi*earthRadius)), d.Distance, "distance should be half of the circumference")
+}
+
+// test for the road factor applied on the straight line
+func TestHaversineCalculator_Road_Factor(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	src := []string{"35.9984617", "-115.1432558"}
+	des := []string{"36.0222811", "-115.0980736"}
+
+	straight, _ := (&haversineCalculator{roadFactor: 1}).Calculate(context.Background(), src, des, Options{})
+	road, err := (&haversineCalculator{roadFactor: 1.5}).Calculate(context.Background(), src, des, Options{})
+
+	a.Nil(err, "distance should be calculated without err")
+	a.InDelta(float64(straight.Distance)*1.5, road.Distance, 1, "distance should be scaled by the road factor")
+}
+
+// test for the same origin and destination
+func TestHaversineCalculator_Same_Point(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c := haversineCalculator{roadFactor: 1}
+
+	d, err := c.Calculate(context.Background(), []string{"35.9984617", "-115.1432558"}, []string{"35.9984617", "-115.1432558"}, Options{})
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal(0, d.Distance, "distance should be zero")
+}
+
+// test for inputs which are not coordinates
+func TestHaversineCalculator_Invalid_Coordinate(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c := haversineCalculator{roadFactor: 1}
+
+	invalid := [][]string{
+		{"foo"},
+		{"foo", "bar"},
+		{"999", "0"},
+		{"0", "999"},
+		{"1", "2", "3"},
+		{"NaN", "0"},
+		{"0", "nan"},
+		{"0", "-Inf"},
+	}
+
+	for _, src := range invalid {
+		_, err := c.Calculate(context.Background(), src, []string{"0", "0"}, Options{})
+		a.Equal(e.ErrDistanceUnknown, err, "distance should be unknown for %v", src)
+	}
+}
+
+// test for the options which change the route are rejected
+func TestHaversineCalculator_Unsupported_Options(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c := haversineCalculator{roadFactor: 1}
+	src, des := []string{"0", "0"}, []string{"0", "1"}
+
+	cases := map[string]Options{
+		"mode":           {Mode: ModeTransit},
+		"avoid":          {Avoid: []string{AvoidHighways}},
+		"departure_time": {DepartureTime: time.Now()},
+	}
+	for field, opts := range cases {
+		_, err := c.Calculate(context.Background(), src, des, opts)
+		if a.IsType(&UnsupportedOptionsError{}, err, "error should be unsupported options for %s", field) {
+			a.Equal(field, err.(*UnsupportedOptionsError).Field, "field should be rejected")
+			a.Equal(ProviderHaversine, err.(*UnsupportedOptionsError).Provider, "provider should be haversine")
+		}
+	}
+
+	// the straight line is the same for the other modes
+	for _, mode := range []string{ModeDriving, ModeWalking, ModeBicycling} {
+		_, err := c.Calculate(context.Background(), src, des, Options{Mode: mode})
+		a.Nil(err, "distance should be calculated for %s", mode)
+	}
+}
@@ -0,0 +1,66 @@
+package distance
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"order-service/pkgs/e"
+	"time"
+)
+
+var (
+	calculateDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "order_service",
+		Name:      "distance_duration_seconds",
+		Help:      "Duration of the distance lookups by provider.",
+	}, []string{"provider"})
+
+	calculateErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "order_service",
+		Name:      "distance_errors_total",
+		Help:      "Failed distance lookups by provider and error type.",
+	}, []string{"provider", "error"})
+)
+
+func init() {
+	prometheus.MustRegister(calculateDuration, calculateErrors)
+}
+
+// decorator recording the latency and errors of every lookup
+type instrumentedCalculator struct {
+	calc     Calculator
+	provider string
+}
+
+// wrap the calculator to record metrics labelled by the provider
+func NewInstrumentedCalculator(calc Calculator, provider string) Calculator {
+	return &instrumentedCalculator{calc: calc, provider: provider}
+}
+
+// calculate with the wrapped calculator and record the result
+func (c *instrumentedCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	start := time.Now()
+	d, err := c.calc.Calculate(ctx, src, des, opts)
+	calculateDuration.WithLabelValues(c.provider).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		calculateErrors.WithLabelValues(c.provider, errorType(err)).Inc()
+	}
+
+	return d, err
+}
+
+// label for the error, known errors get their own type
+func errorType(err error) string {
+	if _, ok := err.(*UnsupportedOptionsError); ok {
+		return "unsupported_options"
+	}
+
+	switch err {
+	case e.ErrDistanceUnknown:
+		return "distance_unknown"
+	case e.ErrTimeout:
+		return "timeout"
+	default:
+		return "other"
+	}
+}
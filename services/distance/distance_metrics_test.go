@@ -0,0 +1,45 @@
+package distance
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"order-service/pkgs/e"
+	"testing"
+)
+
+// test for the decorator return the result of the wrapped calculator
+func TestInstrumentedCalculator(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	c := NewInstrumentedCalculator(NewMockCalculator(100, nil), "test_ok")
+
+	d, err := c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal(100, d.Distance, "distance should come from the wrapped calculator")
+	a.Equal(0.0, testutil.ToFloat64(calculateErrors.WithLabelValues("test_ok", "other")), "no error should be counted")
+}
+
+// test for the decorator count the errors by type
+func TestInstrumentedCalculator_Errors(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	unknown := NewInstrumentedCalculator(NewMockCalculator(0, e.ErrDistanceUnknown), "test_errors")
+	other := NewInstrumentedCalculator(NewMockCalculator(0, errors.New("test for service exception")), "test_errors")
+
+	for i := 0; i < 2; i++ {
+		_, err := unknown.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+		a.Equal(e.ErrDistanceUnknown, err, "error should come from the wrapped calculator")
+	}
+	_, err := other.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+	a.NotNil(err, "error should come from the wrapped calculator")
+
+	a.Equal(2.0, testutil.ToFloat64(calculateErrors.WithLabelValues("test_errors", "distance_unknown")), "unknown distance should be counted")
+	a.Equal(1.0, testutil.ToFloat64(calculateErrors.WithLabelValues("test_errors", "other")), "other error should be counted")
+}
@@ -1,28 +1,38 @@
 package distance
 
+import "context"
+
+// name of the mock on the results
+const ProviderMock = "mock"
+
 type mockCalculator struct {
-	distance int
-	err      *error
+	result Result
+	err    *error
 }
 
 // mock the google distance calculator
-func (m *mockCalculator) Calculate(src []string, des []string) (int, error) {
+func (m *mockCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
 	if m.err == nil {
-		return m.distance, nil
+		return m.result, nil
 	}
 
-	return 0, *m.err
+	return Result{}, *m.err
 }
 
-// initialize the mock calculator
-func InitMockCalculator(d int, err error) {
+// create the mock calculator
+func NewMockCalculator(d int, err error) Calculator {
 	var mock mockCalculator
 
 	if err == nil {
-		mock.distance = d
+		mock.result = Result{Distance: d, Provider: ProviderMock}
 	} else {
 		mock.err = &err
 	}
 
-	calc = &mock
+	return &mock
+}
+
+// create the mock calculator returning the whole result
+func NewMockResultCalculator(res Result) Calculator {
+	return &mockCalculator{result: res}
 }
@@ -0,0 +1,172 @@
+package distance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"order-service/pkgs/e"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// codes of osrm which mean there is no route between the points
+var osrmUnknownCodes = []string{"NoRoute", "NoSegment"}
+
+// travel mode of the common osrm profiles
+var osrmProfileModes = map[string]string{
+	"driving": ModeDriving,
+	"car":     ModeDriving,
+	"walking": ModeWalking,
+	"foot":    ModeWalking,
+	"cycling": ModeBicycling,
+	"bike":    ModeBicycling,
+}
+
+// classes of osrm excluded for the features to avoid
+// Note: the profile of the server has to define the classes
+var osrmExcludeClasses = map[string]string{
+	AvoidTolls:    "toll",
+	AvoidHighways: "motorway",
+	AvoidFerries:  "ferry",
+}
+
+type osrmCalculator struct {
+	baseURL string
+	profile string
+	client  *http.Client
+}
+
+// response of the osrm route service, only the fields in use
+type osrmRouteResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Routes  []struct {
+		// distance in meters
+		Distance float64 `json:"distance"`
+		// duration in seconds
+		Duration float64 `json:"duration"`
+	} `json:"routes"`
+}
+
+// create the calculator calling the route service of the osrm server on the base url
+func NewOSRMCalculator(baseURL string, profile string) (Calculator, error) {
+	if _, err := url.ParseRequestURI(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid osrm base url %q: %v", baseURL, err)
+	}
+
+	var osrmCalc osrmCalculator
+	osrmCalc.baseURL = strings.TrimRight(baseURL, "/")
+	osrmCalc.profile = profile
+	osrmCalc.client = http.DefaultClient
+
+	return &osrmCalc, nil
+}
+
+// calculate the road distance between
+func (c *osrmCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	// osrm only knows coordinates
+	srcLat, srcLng, err := parseCoordinate(src)
+	if err != nil {
+		return Result{}, err
+	}
+
+	desLat, desLng, err := parseCoordinate(des)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// the coordinates are in lng,lat order for osrm
+	coordinates := fmt.Sprintf("%s,%s;%s,%s",
+		formatCoordinate(srcLng), formatCoordinate(srcLat), formatCoordinate(desLng), formatCoordinate(desLat))
+	query, err := c.query(opts)
+	if err != nil {
+		return Result{}, err
+	}
+	reqURL := fmt.Sprintf("%s/route/v1/%s/%s?%s", c.baseURL, c.profile, coordinates, query)
+
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok && urlErr.Timeout() {
+			return Result{}, retryable(err)
+		}
+		return Result{}, err
+	}
+	defer res.Body.Close()
+
+	// the server is overloaded or down, it may answer the next call
+	if res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests {
+		return Result{}, retryable(fmt.Errorf("osrm responded with %s", res.Status))
+	}
+
+	// the errors come with 4xx and the same body as the routes
+	var body osrmRouteResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return Result{}, fmt.Errorf("osrm responded with %s and invalid body: %v", res.Status, err)
+	}
+
+	for _, code := range osrmUnknownCodes {
+		if body.Code == code {
+			return Result{}, e.ErrDistanceUnknown
+		}
+	}
+
+	if body.Code != "Ok" {
+		return Result{}, fmt.Errorf("osrm: %s - %s", body.Code, body.Message)
+	}
+
+	if len(body.Routes) == 0 {
+		return Result{}, e.ErrDistanceUnknown
+	}
+
+	// use the first route since no alternative is asked
+	route := body.Routes[0]
+
+	return Result{
+		Distance: int(math.Round(route.Distance)),
+		Duration: time.Duration(math.Round(route.Duration * float64(time.Second))),
+		Provider: ProviderOSRM,
+		Status:   body.Code,
+	}, nil
+}
+
+// create the query of the route service, a server only routes the mode of its profile
+// Note: osrm does not decode the query so the separators are not escaped
+func (c *osrmCalculator) query(opts Options) (string, error) {
+	if mode, ok := osrmProfileModes[c.profile]; !ok || mode != opts.TravelMode() {
+		return "", unsupported(ProviderOSRM, "mode", opts.TravelMode())
+	}
+
+	if !opts.DepartureTime.IsZero() {
+		return "", unsupported(ProviderOSRM, "departure_time", "departure time")
+	}
+
+	query := "overview=false"
+
+	if len(opts.Avoid) > 0 {
+		classes := make([]string, 0, len(opts.Avoid))
+		for _, avoid := range opts.Avoid {
+			class, ok := osrmExcludeClasses[avoid]
+			if !ok {
+				return "", unsupported(ProviderOSRM, "avoid", avoid)
+			}
+			classes = append(classes, class)
+		}
+		query += "&exclude=" + strings.Join(classes, ",")
+	}
+
+	return query, nil
+}
+
+// format the coordinate without losing precision or using exponent
+func formatCoordinate(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,205 @@
+package distance
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"order-service/pkgs/e"
+	"testing"
+	"time"
+)
+
+// recorded response of the osrm route service
+const osrmOKResponse = `{
+  "code": "Ok",
+  "routes": [{"geometry": "", "legs": [{"steps": [], "summary": "", "weight": 612.4, "duration": 540.3, "distance": 6411.6}],
+    "weight_name": "routability", "weight": 612.4, "duration": 540.3, "distance": 6411.6}],
+  "waypoints": [{"hint": "", "distance": 4.2, "name": "Las Vegas Boulevard", "location": [-115.1728, 36.1146]},
+    {"hint": "", "distance": 8.1, "name": "Green Valley Parkway", "location": [-114.9817, 36.0395]}]
+}`
+
+// recorded response of osrm when the points are not connected
+const osrmNoRouteResponse = `{"code": "NoRoute", "message": "Impossible route between points"}`
+
+// recorded response of osrm when a point is too far from a road
+const osrmNoSegmentResponse = `{"code": "NoSegment", "message": "Could not find a matching segment for coordinate 1"}`
+
+// recorded response of osrm for an invalid request
+const osrmInvalidResponse = `{"code": "InvalidQuery", "message": "Query string malformed close to position 28"}`
+
+// helper function to create the calculator against a stand-in of the osrm server
+// Note: the server has to be closed by the caller
+func newTestOSRMCalculator(t *testing.T, status int, body string, paths *[]string) (Calculator, *httptest.Server) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if paths != nil {
+			*paths = append(*paths, r.URL.RequestURI())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+
+	calc, err := NewOSRMCalculator(srv.URL+"/", "driving")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return calc, srv
+}
+
+// helper function to run a lookup on the test route
+func calculateOSRMRoute(c Calculator) (Result, error) {
+	return c.Calculate(context.Background(), []string{"36.1146", "-115.1728"}, []string{"36.0395", "-114.9817"}, Options{})
+}
+
+// test for the distance parsed from the route
+func TestOSRMCalculator(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	var paths []string
+	calc, srv := newTestOSRMCalculator(t, http.StatusOK, osrmOKResponse, &paths)
+	defer srv.Close()
+
+	d, err := calculateOSRMRoute(calc)
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal(6412, d.Distance, "distance should come from the route")
+	a.Equal(540300*time.Millisecond, d.Duration, "duration should come from the route")
+	a.Equal(ProviderOSRM, d.Provider, "provider should be osrm")
+	a.Equal("Ok", d.Status, "status should be the code of osrm")
+	a.Equal([]string{"/route/v1/driving/-115.1728,36.1146;-114.9817,36.0395?overview=false"}, paths, "coordinates should be sent as lng,lat")
+}
+
+// test for the routes osrm does not know
+func TestOSRMCalculator_Unknown(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	for _, body := range []string{osrmNoRouteResponse, osrmNoSegmentResponse} {
+		calc, srv := newTestOSRMCalculator(t, http.StatusBadRequest, body, nil)
+		_, err := calculateOSRMRoute(calc)
+		srv.Close()
+
+		a.Equal(e.ErrDistanceUnknown, err, "error should be distance unknown for %s", body)
+	}
+}
+
+// test for the input which is not a coordinate never reach osrm
+func TestOSRMCalculator_Not_Coordinate(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	var paths []string
+	calc, srv := newTestOSRMCalculator(t, http.StatusOK, osrmOKResponse, &paths)
+	defer srv.Close()
+
+	_, err := calc.Calculate(context.Background(), []string{"Las Vegas", "NV"}, []string{"36.0395", "-114.9817"}, Options{})
+
+	a.Equal(e.ErrDistanceUnknown, err, "error should be distance unknown")
+	a.Empty(paths, "osrm should not be called")
+}
+
+// test for the errors of osrm marked as retryable or not
+func TestOSRMCalculator_Errors(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	cases := []struct {
+		status    int
+		body      string
+		retryable bool
+	}{
+		{http.StatusBadGateway, `<html>Bad Gateway</html>`, true},
+		{http.StatusServiceUnavailable, ``, true},
+		{http.StatusTooManyRequests, `{"code": "TooBig"}`, true},
+		{http.StatusBadRequest, osrmInvalidResponse, false},
+		{http.StatusOK, `not json`, false},
+	}
+
+	for _, tc := range cases {
+		calc, srv := newTestOSRMCalculator(t, tc.status, tc.body, nil)
+		_, err := calculateOSRMRoute(calc)
+		srv.Close()
+
+		a.NotNil(err, "error should be returned for %d %s", tc.status, tc.body)
+		a.NotEqual(e.ErrDistanceUnknown, err, "error should not be distance unknown for %d %s", tc.status, tc.body)
+		a.Equal(tc.retryable, isRetryable(err), "retryable should match for %d %s", tc.status, tc.body)
+	}
+}
+
+// test for the calculator rejecting an invalid base url
+func TestNewOSRMCalculator_Invalid_URL(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	_, err := NewOSRMCalculator("", "driving")
+
+	a.NotNil(err, "empty base url should be rejected")
+}
+
+// test for the features to avoid sent as the classes osrm excludes
+func TestOSRMCalculator_Avoid(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	var paths []string
+	calc, srv := newTestOSRMCalculator(t, http.StatusOK, osrmOKResponse, &paths)
+	defer srv.Close()
+
+	opts := Options{Avoid: []string{AvoidTolls, AvoidHighways, AvoidFerries}}
+	_, err := calc.Calculate(context.Background(), []string{"36.1146", "-115.1728"}, []string{"36.0395", "-114.9817"}, opts)
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal([]string{"/route/v1/driving/-115.1728,36.1146;-114.9817,36.0395?overview=false&exclude=toll,motorway,ferry"}, paths, "features to avoid should be excluded")
+}
+
+// test for the options osrm can not route
+func TestOSRMCalculator_Unsupported_Options(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	var paths []string
+	calc, srv := newTestOSRMCalculator(t, http.StatusOK, osrmOKResponse, &paths)
+	defer srv.Close()
+
+	cases := map[string]Options{
+		"mode":           {Mode: ModeWalking},
+		"departure_time": {DepartureTime: time.Now()},
+	}
+	for field, opts := range cases {
+		_, err := calc.Calculate(context.Background(), []string{"36.1146", "-115.1728"}, []string{"36.0395", "-114.9817"}, opts)
+		if a.IsType(&UnsupportedOptionsError{}, err, "error should be unsupported options for %s", field) {
+			a.Equal(field, err.(*UnsupportedOptionsError).Field, "field should be rejected")
+		}
+	}
+	a.Empty(paths, "osrm should not be called")
+}
+
+// test for the mode of the profile routed by the server
+func TestOSRMCalculator_Profile_Mode(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(osrmOKResponse))
+	}))
+	defer srv.Close()
+
+	calc, _ := NewOSRMCalculator(srv.URL, "foot")
+
+	_, err := calc.Calculate(context.Background(), []string{"36.1146", "-115.1728"}, []string{"36.0395", "-114.9817"}, Options{Mode: ModeWalking})
+	a.Nil(err, "walking should be routed by the foot profile")
+
+	_, err = calculateOSRMRoute(calc)
+	a.IsType(&UnsupportedOptionsError{}, err, "driving should not be routed by the foot profile")
+}
@@ -0,0 +1,27 @@
+package distance
+
+import (
+	"context"
+	"order-service/pkgs/e"
+)
+
+// a short fixed route used to check the provider is answering
+var (
+	probeSrc = []string{"36.1146", "-115.1728"}
+	probeDes = []string{"36.1212", "-115.1697"}
+)
+
+// create a readiness probe for the calculator
+// Note: every probe is a real call to the provider, so it may cost quota
+func Probe(calc Calculator) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := calc.Calculate(ctx, probeSrc, probeDes, Options{})
+
+		// the provider answered even when it does not know the route
+		if err == e.ErrDistanceUnknown {
+			return nil
+		}
+
+		return err
+	}
+}
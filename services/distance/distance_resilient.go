@@ -0,0 +1,235 @@
+package distance
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"math/rand"
+	"order-service/pkgs/e"
+	"sync"
+	"time"
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// error of a provider which may succeed when the call is repeated
+type retryableError struct {
+	err error
+}
+
+func (r *retryableError) Error() string {
+	return r.err.Error()
+}
+
+// mark the error of the provider as worth another try
+func retryable(err error) error {
+	return &retryableError{err: err}
+}
+
+// check if the lookup may succeed when it is repeated
+func isRetryable(err error) bool {
+	if err == e.ErrTimeout {
+		return true
+	}
+
+	_, ok := err.(*retryableError)
+	return ok
+}
+
+// options of the resilient calculator
+type ResilienceOptions struct {
+	// extra attempts after the first one failed with a retryable error
+	MaxRetries int
+	// wait before the first retry, doubled on every next one
+	BaseBackoff time.Duration
+	// upper bound of the wait between the retries
+	MaxBackoff time.Duration
+	// failed lookups in a row which open the circuit, 0 disables the breaker
+	FailureThreshold int
+	// how long the circuit stays open before a single lookup is let through
+	OpenTimeout time.Duration
+}
+
+// decorator retrying the transient errors and failing fast while the provider is down
+type ResilientCalculator struct {
+	calc     Calculator
+	provider string
+	opts     ResilienceOptions
+	now      func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// wrap the calculator with the retries and the circuit breaker
+func NewResilientCalculator(calc Calculator, provider string, opts ResilienceOptions) *ResilientCalculator {
+	return &ResilientCalculator{calc: calc, provider: provider, opts: opts, now: time.Now, state: CircuitClosed}
+}
+
+// calculate with the wrapped calculator, e.ErrDistanceUnavailable while the circuit is open or the provider fails,
+// e.ErrTimeout when it still does not answer in time after the retries
+func (c *ResilientCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	if !c.allow() {
+		return Result{}, e.ErrDistanceUnavailable
+	}
+
+	d, err := c.retry(ctx, src, des, opts)
+	c.record(ctx, err)
+
+	// the provider is down, hide its error behind a clear one
+	if err != nil && isRetryable(err) {
+		logrus.Warnf("distance provider %s failed after retries: %v", c.provider, err)
+
+		// a provider which hangs stays a timeout so the client can tell it apart from an outage
+		if err == e.ErrTimeout {
+			return Result{}, err
+		}
+		return Result{}, e.ErrDistanceUnavailable
+	}
+
+	return d, err
+}
+
+// return the name of the provider behind the circuit
+func (c *ResilientCalculator) Provider() string {
+	return c.provider
+}
+
+// return the state of the circuit
+func (c *ResilientCalculator) State() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.currentState()
+}
+
+// health probe reporting the open circuit as down without calling the provider
+func (c *ResilientCalculator) Check(ctx context.Context) error {
+	if state := c.State(); state != CircuitClosed {
+		return fmt.Errorf("circuit of the distance provider %s is %s", c.provider, state)
+	}
+
+	return nil
+}
+
+// call the wrapped calculator until it succeeds, fails for good or runs out of attempts
+func (c *ResilientCalculator) retry(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	var (
+		d   Result
+		err error
+	)
+	for attempt := 0; ; attempt++ {
+		d, err = c.calc.Calculate(ctx, src, des, opts)
+		if err == nil || !isRetryable(err) || attempt >= c.opts.MaxRetries {
+			return d, err
+		}
+
+		// wait before the next attempt unless the caller gives up first
+		wait := c.backoff(attempt)
+		logrus.Infof("distance provider %s attempt %d failed, retry in %v: %v", c.provider, attempt+1, wait, err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Result{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// exponential backoff with jitter so the instances do not retry at the same time
+func (c *ResilientCalculator) backoff(attempt int) time.Duration {
+	wait := c.opts.BaseBackoff << uint(attempt)
+	if wait <= 0 || wait > c.opts.MaxBackoff {
+		wait = c.opts.MaxBackoff
+	}
+
+	half := int64(wait / 2)
+	if half <= 0 {
+		return wait
+	}
+
+	return time.Duration(half + rand.Int63n(half))
+}
+
+// check if the lookup can reach the provider, only one lookup is let through a half open circuit
+func (c *ResilientCalculator) allow() bool {
+	if c.opts.FailureThreshold <= 0 {
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.currentState() {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if c.trial {
+			return false
+		}
+		c.trial = true
+		return true
+	default:
+		return false
+	}
+}
+
+// update the circuit with the outcome of the lookup
+func (c *ResilientCalculator) record(ctx context.Context, err error) {
+	if c.opts.FailureThreshold <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.currentState()
+	c.trial = false
+
+	switch {
+	// the caller gave up, it says nothing about the provider
+	case ctx.Err() != nil:
+	// the provider answered, an unknown route is a valid answer
+	case err == nil || !isRetryable(err):
+		c.failures = 0
+		if state != CircuitClosed {
+			c.setState(CircuitClosed)
+		}
+	default:
+		c.failures++
+		if state == CircuitHalfOpen || c.failures >= c.opts.FailureThreshold {
+			c.openedAt = c.now()
+			c.setState(CircuitOpen)
+		}
+	}
+}
+
+// state of the circuit, an open one becomes half open once the timeout passed
+// Note: the caller has to hold the lock
+func (c *ResilientCalculator) currentState() string {
+	if c.state == CircuitOpen && c.now().Sub(c.openedAt) >= c.opts.OpenTimeout {
+		c.setState(CircuitHalfOpen)
+	}
+
+	return c.state
+}
+
+// change the state of the circuit and log the transition
+// Note: the caller has to hold the lock
+func (c *ResilientCalculator) setState(state string) {
+	if c.state == state {
+		return
+	}
+
+	logrus.Warnf("circuit of the distance provider %s changed from %s to %s", c.provider, c.state, state)
+	c.state = state
+}
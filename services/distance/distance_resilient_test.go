@@ -0,0 +1,191 @@
+package distance
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"order-service/pkgs/e"
+	"sync"
+	"testing"
+	"time"
+)
+
+// calculator failing the first calls with the errors before it answers
+type flakyCalculator struct {
+	mu       sync.Mutex
+	calls    int
+	errs     []error
+	distance int
+}
+
+func (c *flakyCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+	if c.calls <= len(c.errs) {
+		return Result{}, c.errs[c.calls-1]
+	}
+
+	return Result{Distance: c.distance}, nil
+}
+
+// options with short waits for the tests
+var testResilienceOptions = ResilienceOptions{
+	MaxRetries:       2,
+	BaseBackoff:      time.Millisecond,
+	MaxBackoff:       5 * time.Millisecond,
+	FailureThreshold: 2,
+	OpenTimeout:      time.Minute,
+}
+
+// helper function to run a lookup on the test route
+func calculateTestRoute(c Calculator) (Result, error) {
+	return c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+}
+
+// test for the decorator retry the transient errors until the provider answers
+func TestResilientCalculator_Retry(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &flakyCalculator{errs: []error{e.ErrTimeout, retryable(errors.New("OVER_QUERY_LIMIT"))}, distance: 1234}
+	c := NewResilientCalculator(inner, "test", testResilienceOptions)
+
+	d, err := calculateTestRoute(c)
+
+	a.Nil(err, "distance should be calculated after the retries")
+	a.Equal(1234, d.Distance, "distance should come from the wrapped calculator")
+	a.Equal(3, inner.calls, "provider should be called until it answers")
+	a.Equal(CircuitClosed, c.State(), "circuit should stay closed")
+}
+
+// test for the decorator give up once the retries are used
+func TestResilientCalculator_Retry_Exhausted(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	overLimit := retryable(errors.New("OVER_QUERY_LIMIT"))
+	inner := &flakyCalculator{errs: []error{e.ErrTimeout, overLimit, overLimit, overLimit}}
+	c := NewResilientCalculator(inner, "test", testResilienceOptions)
+
+	_, err := calculateTestRoute(c)
+
+	a.Equal(e.ErrDistanceUnavailable, err, "error should be distance unavailable")
+	a.Equal(3, inner.calls, "provider should be called once and retried twice")
+}
+
+// test for a provider which does not answer in time after the retries stay a timeout
+func TestResilientCalculator_Retry_Exhausted_Timeout(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	inner := &flakyCalculator{errs: []error{e.ErrTimeout, e.ErrTimeout, e.ErrTimeout, e.ErrTimeout}}
+	c := NewResilientCalculator(inner, "test", testResilienceOptions)
+
+	_, err := calculateTestRoute(c)
+
+	a.Equal(e.ErrTimeout, err, "error should be the timeout")
+	a.Equal(3, inner.calls, "provider should be called once and retried twice")
+}
+
+// test for the decorator not retry the definitive errors
+func TestResilientCalculator_No_Retry(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	denied := errors.New("maps: REQUEST_DENIED - The provided API key is invalid.")
+	for _, err := range []error{e.ErrDistanceUnknown, denied} {
+		inner := &flakyCalculator{errs: []error{err}}
+		c := NewResilientCalculator(inner, "test", testResilienceOptions)
+
+		_, got := calculateTestRoute(c)
+
+		a.Equal(err, got, "error should come from the wrapped calculator")
+		a.Equal(1, inner.calls, "provider should not be retried")
+	}
+}
+
+// test for the open circuit fail fast and let a single lookup through after the timeout
+func TestResilientCalculator_Circuit(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	failures := make([]error, 6)
+	for i := range failures {
+		failures[i] = e.ErrTimeout
+	}
+	inner := &flakyCalculator{errs: failures, distance: 1234}
+	c := NewResilientCalculator(inner, "test", testResilienceOptions)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// two failed lookups in a row open the circuit
+	calculateTestRoute(c)
+	calculateTestRoute(c)
+	a.Equal(CircuitOpen, c.State(), "circuit should be open")
+	a.NotNil(c.Check(context.Background()), "health check should report the open circuit")
+
+	// the provider is not called while the circuit is open
+	_, err := calculateTestRoute(c)
+	a.Equal(e.ErrDistanceUnavailable, err, "error should be distance unavailable")
+	a.Equal(6, inner.calls, "provider should not be called while the circuit is open")
+
+	// the provider is back after the timeout
+	now = now.Add(testResilienceOptions.OpenTimeout)
+	a.Equal(CircuitHalfOpen, c.State(), "circuit should be half open")
+
+	d, err := calculateTestRoute(c)
+	a.Nil(err, "distance should be calculated by the trial lookup")
+	a.Equal(1234, d.Distance, "distance should come from the wrapped calculator")
+	a.Equal(CircuitClosed, c.State(), "circuit should be closed")
+	a.Nil(c.Check(context.Background()), "health check should be OK")
+}
+
+// test for a failed trial lookup open the circuit again
+func TestResilientCalculator_Circuit_Trial_Failed(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	opts := testResilienceOptions
+	opts.MaxRetries = 0
+	inner := &flakyCalculator{errs: []error{e.ErrTimeout, e.ErrTimeout, e.ErrTimeout}}
+	c := NewResilientCalculator(inner, "test", opts)
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	calculateTestRoute(c)
+	calculateTestRoute(c)
+	now = now.Add(opts.OpenTimeout)
+
+	_, err := calculateTestRoute(c)
+	a.Equal(e.ErrTimeout, err, "error should be the timeout of the trial lookup")
+	a.Equal(CircuitOpen, c.State(), "circuit should be open again")
+}
+
+// test for a lookup given up by the caller not count as a failure of the provider
+func TestResilientCalculator_Canceled(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	c := NewResilientCalculator(blockingCalculator{}, "test", testResilienceOptions)
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.Calculate(ctx, []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+		a.Equal(context.Canceled, err, "error should be canceled")
+	}
+
+	a.Equal(CircuitClosed, c.State(), "circuit should stay closed")
+}
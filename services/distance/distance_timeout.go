@@ -0,0 +1,33 @@
+package distance
+
+import (
+	"context"
+	"order-service/pkgs/e"
+	"time"
+)
+
+// decorator giving every lookup its own deadline
+type timeoutCalculator struct {
+	calc    Calculator
+	timeout time.Duration
+}
+
+// wrap the calculator so a single lookup can not take longer than the timeout
+func NewTimeoutCalculator(calc Calculator, timeout time.Duration) Calculator {
+	return &timeoutCalculator{calc: calc, timeout: timeout}
+}
+
+// calculate with the wrapped calculator, e.ErrTimeout when the deadline is hit
+func (c *timeoutCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	d, err := c.calc.Calculate(ctx, src, des, opts)
+
+	// the provider may wrap the context error, so check the deadline itself
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return Result{}, e.ErrTimeout
+	}
+
+	return d, err
+}
@@ -0,0 +1,62 @@
+package distance
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"order-service/pkgs/e"
+	"testing"
+	"time"
+)
+
+// calculator which only returns once the context is done, like a provider which hangs
+type blockingCalculator struct{}
+
+func (blockingCalculator) Calculate(ctx context.Context, src []string, des []string, opts Options) (Result, error) {
+	<-ctx.Done()
+	return Result{}, ctx.Err()
+}
+
+// test for the decorator return the result of a calculator in time
+func TestTimeoutCalculator(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	c := NewTimeoutCalculator(NewMockCalculator(100, nil), time.Second)
+
+	d, err := c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+
+	a.Nil(err, "distance should be calculated without err")
+	a.Equal(100, d.Distance, "distance should come from the wrapped calculator")
+}
+
+// test for the decorator stop a calculator which takes too long
+func TestTimeoutCalculator_Deadline(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	c := NewTimeoutCalculator(blockingCalculator{}, 10*time.Millisecond)
+
+	start := time.Now()
+	_, err := c.Calculate(context.Background(), []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+
+	a.Equal(e.ErrTimeout, err, "error should be timeout")
+	a.True(time.Since(start) < time.Second, "calculator should be stopped at the deadline")
+}
+
+// test for the decorator keep the error when the caller gives up first
+func TestTimeoutCalculator_Canceled(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	c := NewTimeoutCalculator(blockingCalculator{}, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.Calculate(ctx, []string{"1", "2"}, []string{"1.5", "1.6"}, Options{})
+
+	a.Equal(context.Canceled, err, "error should be canceled")
+}
@@ -0,0 +1,33 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"order-service/config"
+)
+
+// error of the geocoder which can not be created without the google map key
+var ErrNoApiKey = errors.New("geocoding needs the map api key")
+
+// coordinate of an address as the geocoder resolved it
+type Place struct {
+	Lat float64
+	Lng float64
+	// address normalized by the geocoder, it may differ from the input
+	FormattedAddress string
+}
+
+// resolver of a free-form address to a coordinate
+// Note: e.ErrAddressNotFound is returned when the address does not match any place
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (Place, error)
+}
+
+// create the geocoder calling google map with the configured key and timeout
+func NewGeocoder(c *config.Configuration) (Geocoder, error) {
+	if c.MapConfig.GetMapApiKey() == "" {
+		return nil, ErrNoApiKey
+	}
+
+	return NewGoogleGeocoder(c.MapConfig.GetMapApiKey(), c.GeocoderConfig.GetTimeout())
+}
@@ -0,0 +1,41 @@
+package geocoding
+
+import (
+	"context"
+	"order-service/pkgs/e"
+	"strings"
+)
+
+// geocoder answering from a fixed list of places, for the tests and the local runs
+type fakeGeocoder struct {
+	places map[string]Place
+}
+
+// create the fake geocoder, the addresses match regardless of the case and the spaces
+func NewFakeGeocoder(places map[string]Place) Geocoder {
+	fake := fakeGeocoder{places: make(map[string]Place, len(places))}
+	for address, place := range places {
+		fake.places[normalizeAddress(address)] = place
+	}
+
+	return &fake
+}
+
+// resolve the address from the list, e.ErrAddressNotFound when it is not there
+func (f *fakeGeocoder) Geocode(ctx context.Context, address string) (Place, error) {
+	if err := ctx.Err(); err != nil {
+		return Place{}, err
+	}
+
+	place, ok := f.places[normalizeAddress(address)]
+	if !ok {
+		return Place{}, e.ErrAddressNotFound
+	}
+
+	return place, nil
+}
+
+// function to lower the case and collapse the spaces of an address
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.Join(strings.Fields(address), " "))
+}
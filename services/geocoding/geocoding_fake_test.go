@@ -0,0 +1,27 @@
+package geocoding
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"order-service/pkgs/e"
+	"testing"
+)
+
+// test for the addresses matched regardless of the case and the spaces
+func TestFakeGeocoder(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	want := Place{Lat: 36.1146, Lng: -115.1728, FormattedAddress: "Las Vegas Strip, Las Vegas, NV, USA"}
+	geocoder := NewFakeGeocoder(map[string]Place{"Las Vegas Strip": want})
+
+	for _, address := range []string{"Las Vegas Strip", "las vegas strip", "  LAS   Vegas strip "} {
+		place, err := geocoder.Geocode(context.Background(), address)
+		a.Nil(err, "%q should be resolved without err", address)
+		a.Equal(want, place, "%q should be resolved to the place", address)
+	}
+
+	_, err := geocoder.Geocode(context.Background(), "Las Vegas")
+	a.Equal(e.ErrAddressNotFound, err, "other address should not be found")
+}
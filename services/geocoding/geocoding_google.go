@@ -0,0 +1,61 @@
+package geocoding
+
+import (
+	"context"
+	"googlemaps.github.io/maps"
+	"order-service/pkgs/e"
+	"time"
+)
+
+type googleGeocoder struct {
+	client  *maps.Client
+	timeout time.Duration
+}
+
+// create the geocoder calling the geocoding api of google map, 0 timeout disables it
+func NewGoogleGeocoder(apiKey string, timeout time.Duration) (Geocoder, error) {
+	return newGoogleGeocoder(timeout, maps.WithAPIKey(apiKey))
+}
+
+// create the geocoder with the options of the google map client
+func newGoogleGeocoder(timeout time.Duration, options ...maps.ClientOption) (Geocoder, error) {
+	c, err := maps.NewClient(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var geocoder googleGeocoder
+	geocoder.client = c
+	geocoder.timeout = timeout
+
+	return &geocoder, nil
+}
+
+// resolve the address with the first result of google
+func (g *googleGeocoder) Geocode(ctx context.Context, address string) (Place, error) {
+	if g.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+
+	res, err := g.client.Geocode(ctx, &maps.GeocodingRequest{Address: address})
+	if err != nil {
+		// the client wraps the context error, so check the deadline itself
+		if ctx.Err() == context.DeadlineExceeded {
+			return Place{}, e.ErrTimeout
+		}
+		return Place{}, err
+	}
+
+	// google answers ZERO_RESULTS without an error
+	if len(res) == 0 {
+		return Place{}, e.ErrAddressNotFound
+	}
+
+	return Place{
+		Lat:              res[0].Geometry.Location.Lat,
+		Lng:              res[0].Geometry.Location.Lng,
+		FormattedAddress: res[0].FormattedAddress,
+	}, nil
+}
@@ -0,0 +1,117 @@
+package geocoding
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"googlemaps.github.io/maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"order-service/pkgs/e"
+	"testing"
+	"time"
+)
+
+// geocoding response with a single result
+const googleOKResponse = `{
+  "status": "OK",
+  "results": [{
+    "formatted_address": "1600 Amphitheatre Pkwy, Mountain View, CA 94043, USA",
+    "geometry": {"location": {"lat": 37.4224764, "lng": -122.0842499}}
+  }]
+}`
+
+// helper function to create the geocoder against a stand-in of the google api
+// Note: the server has to be closed by the caller
+func newTestGoogleGeocoder(t *testing.T, handler http.HandlerFunc, timeout time.Duration) (Geocoder, *httptest.Server) {
+	srv := httptest.NewServer(handler)
+
+	geocoder, err := newGoogleGeocoder(timeout, maps.WithAPIKey("test-key"), maps.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return geocoder, srv
+}
+
+// helper function to answer every call with the body
+func respond(body string, query *url.Values) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if query != nil {
+			*query = r.URL.Query()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+}
+
+// test for the place parsed from the first result
+func TestGoogleGeocoder(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	var query url.Values
+	geocoder, srv := newTestGoogleGeocoder(t, respond(googleOKResponse, &query), 0)
+	defer srv.Close()
+
+	place, err := geocoder.Geocode(context.Background(), "1600 Amphitheatre Parkway")
+
+	a.Nil(err, "address should be resolved without err")
+	a.Equal("1600 Amphitheatre Parkway", query.Get("address"), "address should be sent")
+	a.Equal(37.4224764, place.Lat, "latitude should come from the result")
+	a.Equal(-122.0842499, place.Lng, "longitude should come from the result")
+	a.Equal("1600 Amphitheatre Pkwy, Mountain View, CA 94043, USA", place.FormattedAddress, "address should be formatted by google")
+}
+
+// test for the address google does not know
+func TestGoogleGeocoder_Not_Found(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	geocoder, srv := newTestGoogleGeocoder(t, respond(`{"status": "ZERO_RESULTS", "results": []}`, nil), 0)
+	defer srv.Close()
+
+	_, err := geocoder.Geocode(context.Background(), "Atlantis")
+
+	a.Equal(e.ErrAddressNotFound, err, "error should be address not found")
+}
+
+// test for the error of google
+func TestGoogleGeocoder_Error(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	body := `{"status": "REQUEST_DENIED", "error_message": "The provided API key is invalid."}`
+	geocoder, srv := newTestGoogleGeocoder(t, respond(body, nil), 0)
+	defer srv.Close()
+
+	_, err := geocoder.Geocode(context.Background(), "Las Vegas")
+
+	a.NotNil(err, "error should be returned")
+	a.NotEqual(e.ErrAddressNotFound, err, "error should not be address not found")
+}
+
+// test for the google call which takes longer than the timeout
+func TestGoogleGeocoder_Timeout(t *testing.T) {
+	t.Parallel()
+
+	a := assert.New(t)
+
+	done := make(chan struct{})
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+	}
+	geocoder, srv := newTestGoogleGeocoder(t, slow, 10*time.Millisecond)
+	defer srv.Close()
+	defer close(done)
+
+	_, err := geocoder.Geocode(context.Background(), "Las Vegas")
+
+	a.Equal(e.ErrTimeout, err, "error should be timeout")
+}